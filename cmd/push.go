@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/arunvelsriram/sftp-exporter/pkg/pushgateway"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Pushes the metrics to a Pushgateway, once or on an interval",
+	Run: func(cmd *cobra.Command, args []string) {
+		setUp()
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+		if err := pushgateway.Run(ctx); err != nil {
+			log.Fatalf("Failed to push metrics: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+
+	pushCmd.Flags().String(viperkeys.PushURL, "", "Pushgateway URL")
+	pushCmd.Flags().String(viperkeys.PushJob, "sftp_exporter", "job of the pushed metrics")
+	pushCmd.Flags().StringToString(viperkeys.PushGrouping, nil, "grouping key of the pushed metrics other than the job, like instance=sftp1")
+	pushCmd.Flags().Duration(viperkeys.PushInterval, 0, "interval between pushes, 0 to push once and exit")
+	pushCmd.Flags().String(viperkeys.PushUsername, "", "Pushgateway basic auth user")
+	pushCmd.Flags().String(viperkeys.PushPassword, "", "Pushgateway basic auth password")
+	pushCmd.Flags().String(viperkeys.PushTLSCAFile, "", "CA certificate to verify the Pushgateway with")
+	pushCmd.Flags().String(viperkeys.PushTLSCertFile, "", "client certificate for the Pushgateway")
+	pushCmd.Flags().String(viperkeys.PushTLSKeyFile, "", "client key for the Pushgateway")
+	pushCmd.Flags().Bool(viperkeys.PushTLSSkipVerify, false, "skip verifying the certificate of the Pushgateway")
+
+	if err := viper.BindPFlags(pushCmd.Flags()); err != nil {
+		log.Fatalf("Viper failed to bind flags: %v", err)
+	}
+}
@@ -1,13 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
 	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/arunvelsriram/sftp-exporter/pkg/logging"
 	"github.com/arunvelsriram/sftp-exporter/pkg/server"
+	"github.com/prometheus/exporter-toolkit/web"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -17,26 +26,49 @@ var rootCmd = &cobra.Command{
 	Use:   "sftp-exporter",
 	Short: "Prometheus Exporter for SFTP.",
 	Run: func(cmd *cobra.Command, args []string) {
-		level, err := log.ParseLevel(viper.GetString(viperkeys.LogLevel))
-		if err != nil {
-			log.Fatalf("Failed to set log level: %v", err)
+		setUp()
+		if viper.GetInt(viperkeys.LogRequestsSample) < 0 {
+			log.Fatalf("Invalid %s: must not be negative", viperkeys.LogRequestsSample)
 		}
-		log.SetLevel(level)
-
-		log.Debugf("All configs:")
-		for key, value := range viper.AllSettings() {
-			if key == viperkeys.SFTPPassword || key == viperkeys.SFTPKey || key == viperkeys.SFTPKeyPassphrase {
-				value = "**********"
-			}
-			log.Debugf("%s: %v", key, value)
+		if err := web.Validate(viper.GetString(viperkeys.WebConfigFile)); err != nil {
+			log.Fatalf("Invalid web config: %v", err)
 		}
 
-		if err = server.Start(); err != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+		if err := server.Start(ctx, version); err != nil {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	},
 }
 
+// setUp applies the log settings and validates the config shared by the
+// commands collecting the SFTP metrics.
+func setUp() {
+	level, err := log.ParseLevel(viper.GetString(viperkeys.LogLevel))
+	if err != nil {
+		log.Fatalf("Failed to set log level: %v", err)
+	}
+	log.SetLevel(level)
+	formatter, err := logging.NewFormatter(viper.GetString(viperkeys.LogFormat))
+	if err != nil {
+		log.Fatalf("Failed to set log format: %v", err)
+	}
+	log.SetFormatter(formatter)
+
+	log.Debugf("All configs:")
+	for key, value := range viper.AllSettings() {
+		if key == viperkeys.SFTPPassword || key == viperkeys.SFTPKey || key == viperkeys.SFTPKeyPassphrase {
+			value = "**********"
+		}
+		log.Debugf("%s: %v", key, value)
+	}
+
+	if err = collector.ValidateConfig(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatalf("Failed to execute command: %v\n", err)
@@ -47,34 +79,74 @@ func init() {
 	log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.Flags().StringVarP(&configFile, viperkeys.ConfigFile, "c", "sftp-exporter.yaml", "exporter config file")
+	rootCmd.PersistentFlags().StringVarP(&configFile, viperkeys.ConfigFile, "c", "sftp-exporter.yaml", "exporter config file")
 	rootCmd.Flags().String(viperkeys.BindAddress, "127.0.0.1", "exporter bind address")
 	rootCmd.Flags().Int(viperkeys.Port, 8080, "exporter port")
+	rootCmd.Flags().Int(viperkeys.ReadyMaxFailures, 1, "number of consecutive failed connections to SFTP after which the exporter is not ready, 0 to ignore them")
+	rootCmd.Flags().Duration(viperkeys.ReadyMaxAge, 0, "maximum age of the last successful collection for the exporter to be ready, 0 to ignore it")
+	rootCmd.Flags().Duration(viperkeys.ShutdownGracePeriod, 15*time.Second, "maximum duration to wait for the requests in progress when shutting down")
+	otlpProtocolUsage := fmt.Sprintf("OTLP protocol [%s | %s]", server.OTLPProtocolGRPC, server.OTLPProtocolHTTP)
+	rootCmd.Flags().String(viperkeys.OTLPEndpoint, "", "OTLP endpoint to also export the metrics to, like otel-collector:4317")
+	rootCmd.Flags().String(viperkeys.OTLPProtocol, server.OTLPProtocolGRPC, otlpProtocolUsage)
+	rootCmd.Flags().Duration(viperkeys.OTLPInterval, time.Minute, "interval between OTLP exports")
+	rootCmd.Flags().Bool(viperkeys.OTLPInsecure, false, "export to the OTLP endpoint without TLS")
+	rootCmd.Flags().String(viperkeys.RemoteWriteURL, "", "remote-write endpoint to also send the SFTP metrics to")
+	rootCmd.Flags().Duration(viperkeys.RemoteWriteInterval, time.Minute, "interval between remote writes")
+	rootCmd.Flags().StringToString(viperkeys.RemoteWriteLabels, nil, "labels added to the remotely written series, like site=edge1")
+	rootCmd.Flags().Int(viperkeys.RemoteWriteQueueCapacity, 60, "maximum number of write requests waiting for the remote-write endpoint")
+	rootCmd.Flags().Duration(viperkeys.RemoteWriteMinBackoff, time.Second, "initial delay before retrying a failed remote write")
+	rootCmd.Flags().Duration(viperkeys.RemoteWriteMaxBackoff, time.Minute, "maximum delay before retrying a failed remote write")
+	rootCmd.Flags().String(viperkeys.RemoteWriteUsername, "", "remote-write basic auth user")
+	rootCmd.Flags().String(viperkeys.RemoteWritePassword, "", "remote-write basic auth password")
+	rootCmd.Flags().String(viperkeys.WebConfigFile, "", "web config file enabling TLS or basic auth, re-read on every request")
+	rootCmd.Flags().Bool(viperkeys.WebEnableLifecycle, false, "enable reloading the config with POST /-/reload")
 	var logLevels = make([]string, len(log.AllLevels))
 	for i, level := range log.AllLevels {
 		logLevels[i] = level.String()
 	}
 	logLevelUsage := fmt.Sprintf("log level [%s]", strings.Join(logLevels, " | "))
 
-	rootCmd.Flags().String(viperkeys.LogLevel, log.InfoLevel.String(), logLevelUsage)
-	rootCmd.Flags().String(viperkeys.SFTPHost, "localhost", "SFTP host")
-	rootCmd.Flags().Int(viperkeys.SFTPPort, 22, "SFTP port")
-	rootCmd.Flags().String(viperkeys.SFTPUser, "", "SFTP user")
-	rootCmd.Flags().String(viperkeys.SFTPPassword, "", "SFTP password")
-	rootCmd.Flags().String(viperkeys.SFTPKey, "", "SFTP key (base64 encoded)")
-	rootCmd.Flags().String(viperkeys.SFTPKeyPassphrase, "", "SFTP key passphrase")
-	rootCmd.Flags().Bool(viperkeys.SFTPStatVfs, true, "Use StatVFS extension features")
-	rootCmd.Flags().StringSlice(viperkeys.SFTPPaths, []string{"/"}, "SFTP paths")
-	rootCmd.Flags().String(viperkeys.SFTPTimeout, "10s", "SFTP connection timeout")
+	rootCmd.PersistentFlags().String(viperkeys.LogLevel, log.InfoLevel.String(), logLevelUsage)
+	logFormatUsage := fmt.Sprintf("log format [%s | %s | %s]", logging.FormatText, logging.FormatJSON, logging.FormatLogfmt)
+	rootCmd.PersistentFlags().String(viperkeys.LogFormat, logging.FormatText, logFormatUsage)
+	rootCmd.Flags().Int(viperkeys.LogRequestsSample, 1, "log one in N requests to /metrics and /healthz, 0 to only log the failed ones")
+	rootCmd.PersistentFlags().Duration(viperkeys.ScrapeTimeout, 0, "maximum duration of a scrape, 0 for no limit other than the Prometheus scrape timeout")
+	rootCmd.Flags().Duration(viperkeys.ScrapeTimeoutOffset, 500*time.Millisecond, "offset to subtract from the Prometheus scrape timeout")
+	rootCmd.PersistentFlags().String(viperkeys.SFTPHost, "localhost", "SFTP host")
+	rootCmd.PersistentFlags().Int(viperkeys.SFTPPort, 22, "SFTP port")
+	rootCmd.PersistentFlags().String(viperkeys.SFTPUser, "", "SFTP user")
+	rootCmd.PersistentFlags().String(viperkeys.SFTPPassword, "", "SFTP password")
+	rootCmd.PersistentFlags().String(viperkeys.SFTPKey, "", "SFTP key (base64 encoded)")
+	rootCmd.PersistentFlags().String(viperkeys.SFTPKeyPassphrase, "", "SFTP key passphrase")
+	rootCmd.PersistentFlags().Bool(viperkeys.SFTPStatVfs, true, "Use StatVFS extension features")
+	rootCmd.PersistentFlags().StringSlice(viperkeys.SFTPPaths, []string{"/"}, "SFTP paths")
+	rootCmd.PersistentFlags().String(viperkeys.SFTPTimeout, "10s", "SFTP connection timeout")
+	rootCmd.PersistentFlags().Duration(viperkeys.SFTPPathTimeout, 0, "maximum duration of collecting the object metrics of a path, 0 for no limit")
+	rootCmd.PersistentFlags().Int(viperkeys.SFTPMaxEntries, 0, "maximum number of entries to walk in a path, 0 for no limit")
+	symlinksUsage := fmt.Sprintf("policy for symbolic links [%s | %s | %s]",
+		collector.SymlinksSkip, collector.SymlinksCount, collector.SymlinksFollow)
+	rootCmd.PersistentFlags().String(viperkeys.SFTPSymlinks, collector.SymlinksCount, symlinksUsage)
+	rootCmd.PersistentFlags().Int(viperkeys.SFTPMaxOwners, 10, "maximum number of uids and gids to report per path")
+	rootCmd.PersistentFlags().Bool(viperkeys.SFTPIncremental, false, "only list the directories whose modification time changed since the previous walk")
+	rootCmd.PersistentFlags().Int(viperkeys.SFTPMaxTrackedObjects, 0, "maximum number of objects in a path to track for changes, 0 to disable")
+	rootCmd.PersistentFlags().Duration(viperkeys.SFTPStableAfter, 0, "duration objects must keep the same size and modification time to be available, 0 to count all the objects")
+	rootCmd.PersistentFlags().StringSlice(viperkeys.SFTPRecentWindows, nil, "windows to count the objects modified within, like 15m,1h,24h")
+	rootCmd.PersistentFlags().String(viperkeys.SFTPTimezone, "Local", "timezone of the dates in templated SFTP paths")
+	rootCmd.PersistentFlags().StringSlice(viperkeys.SFTPFiles, nil, "SFTP files whose content is inspected")
+	rootCmd.PersistentFlags().Int64(viperkeys.SFTPMaxFileSize, 1<<20, "maximum size in bytes of the files whose content is inspected")
+	rootCmd.PersistentFlags().Int(viperkeys.SFTPMaxConcurrency, 1, "maximum number of concurrent walks over the SFTP connection")
+	rootCmd.PersistentFlags().Int(viperkeys.SFTPWalkPrefetch, 0, "number of directories each of the concurrent walks lists ahead, 0 to list them one at a time")
 
-	err := viper.BindPFlags(rootCmd.Flags())
-	if err != nil {
-		log.Fatalf("Viper failed to bind flags: %v", err)
+	// the persistent flags are shared with the push command
+	for _, flags := range []*pflag.FlagSet{rootCmd.PersistentFlags(), rootCmd.Flags()} {
+		if err := viper.BindPFlags(flags); err != nil {
+			log.Fatalf("Viper failed to bind flags: %v", err)
+		}
 	}
 }
 
 func initConfig() {
-	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_")) // replace - in cmdline flags to _ in env vars
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_")) // replace - and . in cmdline flags to _ in env vars
 	viper.SetConfigFile(configFile)
 	if err := viper.ReadInConfig(); err != nil {
 		log.Warnf("Failed to load config file %s: %v", configFile, err)
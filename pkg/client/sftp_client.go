@@ -1,9 +1,14 @@
 package client
 
 import (
-	"github.com/kr/fs"
+	"io"
+	"os"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/client/walk"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
 	"github.com/pkg/sftp"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -12,28 +17,44 @@ type (
 		Connect() error
 		Close() error
 		StatVFS(path string) (*sftp.StatVFS, error)
-		Walk(root string) *fs.Walker
+		Walk(root string) *walk.Walker
+		Stat(path string) (os.FileInfo, error)
+		RealPath(path string) (string, error)
+		Open(path string) (io.ReadCloser, error)
+		Glob(pattern string) ([]string, error)
 	}
 
 	sftpClient struct {
 		*sftp.Client
 		sshClient *ssh.Client
+		target    string
 	}
 )
 
+// Walk walks the root, listing up to sftp-walk-prefetch directories ahead of
+// the walk in addition to the directory being walked.
+func (s *sftpClient) Walk(root string) *walk.Walker {
+	return walk.New(root, s.Client, viper.GetInt(viperkeys.SFTPWalkPrefetch))
+}
+
+func (s *sftpClient) Open(path string) (io.ReadCloser, error) {
+	return s.Client.Open(path)
+}
+
 func (s *sftpClient) Close() error {
 	if err := s.Client.Close(); err != nil {
-		log.WithField("when", "closing SFTP connection").Error(err)
+		log.WithFields(log.Fields{"stage": "closing SFTP connection", "target": s.target}).WithError(err).Error("failed to close the SFTP connection")
 		return err
 	}
 	if err := s.sshClient.Close(); err != nil {
-		log.WithField("when", "closing SSH connection").Error(err)
+		log.WithFields(log.Fields{"stage": "closing SSH connection", "target": s.target}).WithError(err).Error("failed to close the SSH connection")
 		return err
 	}
 	return nil
 }
 
 func (s *sftpClient) Connect() (err error) {
+	s.target = Target()
 	s.sshClient, err = NewSSHClient()
 	if err != nil {
 		return err
@@ -42,7 +63,7 @@ func (s *sftpClient) Connect() (err error) {
 	s.Client, err = sftp.NewClient(s.sshClient)
 	if err != nil {
 		if err := s.sshClient.Close(); err != nil {
-			log.WithField("when", "opening SFTP connection").Error(err)
+			log.WithFields(log.Fields{"stage": "opening SFTP connection", "target": s.target}).WithError(err).Error("failed to close the SSH connection")
 		}
 		return err
 	}
@@ -15,7 +15,7 @@ func parsePrivateKey(key, keyPassphrase []byte) (parsedKey ssh.Signer, err error
 		log.Debug("key has passphrase")
 		parsedKey, err = ssh.ParsePrivateKeyWithPassphrase(key, keyPassphrase)
 		if err != nil {
-			log.WithField("when", "parsing encrypted ssh key").
+			log.WithField("stage", "parsing encrypted ssh key").WithError(err).
 				Error("failed to parse key with passphrase")
 			return nil, err
 		}
@@ -25,7 +25,7 @@ func parsePrivateKey(key, keyPassphrase []byte) (parsedKey ssh.Signer, err error
 	log.Debug("key has no passphrase")
 	parsedKey, err = ssh.ParsePrivateKey(key)
 	if err != nil {
-		log.WithField("when", "parsing ssh key").Error("failed to parse key")
+		log.WithField("stage", "parsing ssh key").WithError(err).Error("failed to parse key")
 		return nil, err
 	}
 	return parsedKey, err
@@ -44,7 +44,7 @@ func sshAuthMethods() ([]ssh.AuthMethod, error) {
 		log.Debug("key and password are provided")
 		parsedKey, err := parsePrivateKey(key, keyPassphrase)
 		if err != nil {
-			log.WithField("when", "determining SSH authentication methods").Error(err)
+			log.WithField("stage", "determining SSH authentication methods").WithError(err).Error("failed to use the SSH key")
 			return nil, err
 		}
 		return []ssh.AuthMethod{
@@ -61,7 +61,7 @@ func sshAuthMethods() ([]ssh.AuthMethod, error) {
 		log.Debug("key is provided")
 		parsedKey, err := parsePrivateKey(key, keyPassphrase)
 		if err != nil {
-			log.WithField("when", "determining SSH authentication methods").Error(err)
+			log.WithField("stage", "determining SSH authentication methods").WithError(err).Error("failed to use the SSH key")
 			return nil, err
 		}
 		return []ssh.AuthMethod{
@@ -73,11 +73,16 @@ func sshAuthMethods() ([]ssh.AuthMethod, error) {
 	return nil, fmt.Errorf("failed to determine the SSH authentication methods to use")
 }
 
+// Target returns the address of the SFTP server, as host:port.
+func Target() string {
+	return fmt.Sprintf("%s:%d", viper.GetString(viperkeys.SFTPHost), viper.GetInt(viperkeys.SFTPPort))
+}
+
 func NewSSHClient() (*ssh.Client, error) {
-	addr := fmt.Sprintf("%s:%d", viper.GetString(viperkeys.SFTPHost), viper.GetInt(viperkeys.SFTPPort))
+	addr := Target()
 	auth, err := sshAuthMethods()
 	if err != nil {
-		log.WithField("when", "creating a SSH client").Error(err)
+		log.WithFields(log.Fields{"stage": "creating a SSH client", "target": addr}).WithError(err).Error("failed to authenticate")
 		return nil, err
 	}
 	clientConfig := &ssh.ClientConfig{
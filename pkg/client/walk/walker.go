@@ -0,0 +1,163 @@
+// Package walk walks the file trees of an SFTP server.
+package walk
+
+import (
+	"os"
+
+	"github.com/kr/fs"
+)
+
+type (
+	// Walker walks a file tree like fs.Walker, visiting a directory right
+	// before its entries, using the attributes returned along with the
+	// entries of the directories. The directories coming next in the walk
+	// are listed ahead in the background, so that the requests to the
+	// server are pipelined rather than waiting for one another.
+	Walker struct {
+		fs      fs.FileSystem
+		cur     item
+		stack   []item
+		descend bool
+		// dirs holds the indexes of the directories in the stack, the
+		// next one to be visited being last
+		dirs        []int
+		sem         chan struct{}
+		prefetchDir func(path string, info os.FileInfo) bool
+	}
+
+	item struct {
+		path    string
+		info    os.FileInfo
+		err     error
+		listing *listing
+	}
+
+	// listing is the result of listing a directory ahead of the walk.
+	listing struct {
+		done  chan struct{}
+		infos []os.FileInfo
+		err   error
+	}
+)
+
+// New returns a Walker rooted at root on the file system, listing up to
+// prefetch directories ahead of the walk at once.
+func New(root string, fileSystem fs.FileSystem, prefetch int) *Walker {
+	info, err := fileSystem.Lstat(root)
+	return &Walker{fs: fileSystem, stack: []item{{path: root, info: info, err: err}}, sem: newSem(prefetch)}
+}
+
+func newSem(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
+// Step advances the Walker to the next file or directory, which will then be
+// available through the Path, Stat, and Err methods. It returns false when
+// the walk stops at the end of the tree.
+func (w *Walker) Step() bool {
+	if w.descend && w.cur.err == nil && w.cur.info.IsDir() {
+		infos, err := w.list(w.cur)
+		if err != nil {
+			w.cur.err = err
+			w.cur.listing = nil
+			w.stack = append(w.stack, w.cur)
+		} else {
+			for i := len(infos) - 1; i >= 0; i-- {
+				path := w.fs.Join(w.cur.path, infos[i].Name())
+				if infos[i].IsDir() {
+					w.dirs = append(w.dirs, len(w.stack))
+				}
+				w.stack = append(w.stack, item{path: path, info: infos[i]})
+			}
+		}
+	}
+
+	if len(w.stack) == 0 {
+		return false
+	}
+	i := len(w.stack) - 1
+	w.cur = w.stack[i]
+	w.stack = w.stack[:i]
+	if n := len(w.dirs); n > 0 && w.dirs[n-1] == i {
+		w.dirs = w.dirs[:n-1]
+	}
+	w.descend = true
+	w.prefetch()
+	return true
+}
+
+// Path returns the path to the most recent file or directory visited by a
+// call to Step.
+func (w *Walker) Path() string {
+	return w.cur.path
+}
+
+// Stat returns info for the most recent file or directory visited by a call
+// to Step.
+func (w *Walker) Stat() os.FileInfo {
+	return w.cur.info
+}
+
+// Err returns the error, if any, for the most recent attempt by Step to visit
+// a file or directory. If a directory has an error, w will not descend into
+// that directory.
+func (w *Walker) Err() error {
+	return w.cur.err
+}
+
+// SkipDir causes the currently visited directory to be skipped. If w is not
+// on a directory, SkipDir has no effect. A listing of the directory made
+// ahead of the walk is dropped.
+func (w *Walker) SkipDir() {
+	w.descend = false
+}
+
+// Detach returns a Walker of the directory being visited, reusing its
+// listing made ahead of the walk, if any. It is meant to hand the directory
+// over to another walk, w then skipping it with SkipDir.
+func (w *Walker) Detach() *Walker {
+	return &Walker{fs: w.fs, stack: []item{w.cur}, sem: newSem(cap(w.sem)), prefetchDir: w.prefetchDir}
+}
+
+// PrefetchIf restricts listing ahead of the walk to the directories for which
+// prefetch returns true, like the directories whose listing is not known yet.
+func (w *Walker) PrefetchIf(prefetch func(path string, info os.FileInfo) bool) {
+	w.prefetchDir = prefetch
+}
+
+func (w *Walker) list(dir item) ([]os.FileInfo, error) {
+	if dir.listing == nil {
+		return w.fs.ReadDir(dir.path)
+	}
+	<-dir.listing.done
+	return dir.listing.infos, dir.listing.err
+}
+
+// prefetch starts listing the directories coming next in the walk, the
+// listings of the directories that are further down the stack being started
+// once their turn gets closer.
+func (w *Walker) prefetch() {
+	if w.sem == nil {
+		return
+	}
+	for j := len(w.dirs) - 1; j >= 0 && j >= len(w.dirs)-cap(w.sem); j-- {
+		dir := &w.stack[w.dirs[j]]
+		if dir.listing != nil || (w.prefetchDir != nil && !w.prefetchDir(dir.path, dir.info)) {
+			continue
+		}
+		select {
+		case w.sem <- struct{}{}:
+		default:
+			return
+		}
+		dir.listing = &listing{done: make(chan struct{})}
+		go func(path string, listing *listing) {
+			defer func() { <-w.sem }()
+			listing.infos, listing.err = w.fs.ReadDir(path)
+			close(listing.done)
+		}(dir.path, dir.listing)
+	}
+}
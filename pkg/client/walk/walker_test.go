@@ -0,0 +1,239 @@
+package walk
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kr/fs"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type visit struct {
+	path string
+	err  bool
+}
+
+// localFs is the local file system, listing directories in lexical order.
+type localFs struct{}
+
+func (localFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, len(entries))
+	for i, entry := range entries {
+		if infos[i], err = entry.Info(); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+func (localFs) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (localFs) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+// recordingFs records the directories that are listed.
+type recordingFs struct {
+	fs.FileSystem
+	mu   sync.Mutex
+	dirs []string
+}
+
+func (r *recordingFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	r.mu.Lock()
+	r.dirs = append(r.dirs, dirname)
+	r.mu.Unlock()
+	return r.FileSystem.ReadDir(dirname)
+}
+
+func (r *recordingFs) listed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.dirs...)
+}
+
+func makeTree(t testing.TB, dirs, files int) string {
+	root := t.TempDir()
+	for i := range dirs {
+		dir := filepath.Join(root, fmt.Sprintf("dir%02d", i), "sub")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		for j := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%02d.txt", j)), []byte("file"), 0644))
+		}
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(root, "skipped"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "skipped", "file.txt"), []byte("file"), 0644))
+	return root
+}
+
+type stepper interface {
+	Step() bool
+	Path() string
+	Err() error
+	Stat() os.FileInfo
+	SkipDir()
+}
+
+func walkAll(w stepper) []visit {
+	var visits []visit
+	for w.Step() {
+		visits = append(visits, visit{path: w.Path(), err: w.Err() != nil})
+		if w.Err() == nil && w.Stat().IsDir() && filepath.Base(w.Path()) == "skipped" {
+			w.SkipDir()
+		}
+	}
+	return visits
+}
+
+func TestWalkerShouldVisitLikeKrFsWalker(t *testing.T) {
+	root := makeTree(t, 5, 3)
+	expected := walkAll(fs.Walk(root))
+
+	for _, prefetch := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("prefetch %d", prefetch), func(t *testing.T) {
+			assert.Equal(t, expected, walkAll(New(root, localFs{}, prefetch)))
+		})
+	}
+}
+
+func TestWalkerShouldReturnErrorWhenRootIsMissing(t *testing.T) {
+	w := New("/missing", localFs{}, 4)
+
+	assert.True(t, w.Step())
+	assert.ErrorIs(t, w.Err(), os.ErrNotExist)
+	assert.False(t, w.Step())
+}
+
+func TestWalkerShouldOnlyPrefetchDirectoriesMatchingThePredicate(t *testing.T) {
+	root := makeTree(t, 3, 1)
+	fileSystem := &recordingFs{FileSystem: localFs{}}
+	w := New(root, fileSystem, 4)
+	w.PrefetchIf(func(path string, info os.FileInfo) bool {
+		return filepath.Base(path) != "skipped"
+	})
+
+	walkAll(w)
+
+	assert.NotContains(t, fileSystem.listed(), filepath.Join(root, "skipped"))
+	assert.Len(t, fileSystem.listed(), 1+3*2)
+}
+
+func TestWalkerShouldReuseListingOfDetachedDirectory(t *testing.T) {
+	root := makeTree(t, 3, 1)
+	expected := walkAll(fs.Walk(root))
+	fileSystem := &recordingFs{FileSystem: localFs{}}
+	w := New(root, fileSystem, 4)
+	w.PrefetchIf(func(path string, info os.FileInfo) bool {
+		return filepath.Base(path) != "skipped"
+	})
+
+	var visits []visit
+	for w.Step() {
+		visits = append(visits, visit{path: w.Path(), err: w.Err() != nil})
+		if w.Err() == nil && w.Stat().IsDir() && w.Path() != root {
+			w.SkipDir()
+			if filepath.Base(w.Path()) != "skipped" {
+				visits = append(visits, walkAll(w.Detach())[1:]...)
+			}
+		}
+	}
+
+	assert.Equal(t, expected, visits)
+	assert.ElementsMatch(t, []string{
+		root,
+		filepath.Join(root, "dir00"), filepath.Join(root, "dir00", "sub"),
+		filepath.Join(root, "dir01"), filepath.Join(root, "dir01", "sub"),
+		filepath.Join(root, "dir02"), filepath.Join(root, "dir02", "sub"),
+	}, fileSystem.listed())
+}
+
+// latencyWriter delivers the writes after the latency, without holding the
+// writes that follow, like a network link.
+type latencyWriter struct {
+	io.WriteCloser
+	latency time.Duration
+	chunks  chan chunk
+}
+
+type chunk struct {
+	data []byte
+	at   time.Time
+}
+
+func newLatencyWriter(w io.WriteCloser, latency time.Duration) *latencyWriter {
+	l := &latencyWriter{WriteCloser: w, latency: latency, chunks: make(chan chunk, 1024)}
+	go func() {
+		for c := range l.chunks {
+			time.Sleep(time.Until(c.at))
+			if _, err := l.WriteCloser.Write(c.data); err != nil {
+				return
+			}
+		}
+	}()
+	return l
+}
+
+func (l *latencyWriter) Write(p []byte) (int, error) {
+	l.chunks <- chunk{data: append([]byte(nil), p...), at: time.Now().Add(l.latency)}
+	return len(p), nil
+}
+
+// newTestClient returns a client of an SFTP server serving the local file
+// system in process, whose requests take latency to reach the server.
+func newTestClient(t testing.TB, latency time.Duration) *sftp.Client {
+	serverConn, clientConn := net.Pipe()
+	server, err := sftp.NewServer(serverConn)
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+
+	var wr io.WriteCloser = clientConn
+	if latency > 0 {
+		wr = newLatencyWriter(clientConn, latency)
+	}
+	client, err := sftp.NewClientPipe(clientConn, wr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client
+}
+
+func TestWalkerShouldWalkSFTPServer(t *testing.T) {
+	root := makeTree(t, 5, 3)
+	client := newTestClient(t, 0)
+
+	assert.Equal(t, walkAll(client.Walk(root)), walkAll(New(root, client, 4)))
+}
+
+func BenchmarkWalker(b *testing.B) {
+	root := makeTree(b, 20, 10)
+	client := newTestClient(b, time.Millisecond)
+
+	b.Run("kr/fs", func(b *testing.B) {
+		for range b.N {
+			walkAll(client.Walk(root))
+		}
+	})
+	for _, prefetch := range []int{0, 8} {
+		b.Run(fmt.Sprintf("prefetch %d", prefetch), func(b *testing.B) {
+			for range b.N {
+				walkAll(New(root, client, prefetch))
+			}
+		})
+	}
+}
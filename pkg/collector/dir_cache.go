@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"maps"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// cacheMinAge is how old the modification time of a directory must be for
+// its listing to be cached. SFTP reports modification times in seconds, so a
+// directory changing within the second it was listed would keep its
+// modification time.
+const cacheMinAge = 2 * time.Second
+
+type (
+	// dirListing holds the stats of the entries of a directory other than
+	// its subdirectories, as of the modification time of the directory.
+	dirListing struct {
+		modTime   time.Time
+		entries   int
+		stats     objectStats
+		subdirs   []string
+		cacheable bool
+	}
+
+	// dirCache holds the listings of the directories of a path between
+	// walks, so that the directories whose modification time did not change
+	// are not listed again. Changes to the files that keep their directory
+	// untouched, like appending to a file, are not seen until the directory
+	// changes.
+	dirCache struct {
+		config   pathConfig
+		mu       sync.Mutex
+		listings map[string]*dirListing
+		next     map[string]*dirListing
+		hits     int
+		lookups  int
+	}
+)
+
+func newDirListing(modTime time.Time) *dirListing {
+	return &dirListing{modTime: modTime, cacheable: true}
+}
+
+func newDirCache(config pathConfig) *dirCache {
+	return &dirCache{config: config, listings: make(map[string]*dirListing)}
+}
+
+// matches tells if the listings were cached with the same settings, as the
+// stats of the objects depend on them.
+func (d *dirCache) matches(config pathConfig) bool {
+	return reflect.DeepEqual(d.config, config)
+}
+
+// begin starts collecting the listings of a walk.
+func (d *dirCache) begin() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.next = make(map[string]*dirListing)
+	d.hits = 0
+	d.lookups = 0
+}
+
+// lookup returns the listing of the directory if its modification time did
+// not change since it was cached.
+func (d *dirCache) lookup(dir string, modTime time.Time) *dirListing {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lookups++
+	listing, ok := d.listings[dir]
+	if !ok || !listing.modTime.Equal(modTime) || d.next == nil {
+		return nil
+	}
+	d.hits++
+	d.next[dir] = listing
+	return listing
+}
+
+// has tells if the directory has a listing as of its modification time, without
+// counting as a lookup.
+func (d *dirCache) has(dir string, modTime time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	listing, ok := d.listings[dir]
+	return ok && listing.modTime.Equal(modTime)
+}
+
+func (d *dirCache) store(dir string, listing *dirListing) {
+	if !listing.cacheable || time.Since(listing.modTime) < cacheMinAge {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.next != nil {
+		d.next[dir] = listing
+	}
+}
+
+// commit replaces the listings by the ones of the walk, dropping the
+// directories that are gone. The listings of an incomplete walk are added to
+// the previous ones instead.
+func (d *dirCache) commit(complete bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if complete {
+		d.listings = d.next
+	} else {
+		maps.Copy(d.listings, d.next)
+	}
+	d.next = nil
+}
+
+// hitRatio returns the ratio of the directories of the last walk whose
+// listing was reused.
+func (d *dirCache) hitRatio() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lookups == 0 {
+		return 0
+	}
+	return float64(d.hits) / float64(d.lookups)
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/client"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// defaultMatchBytes is the number of bytes matched when a file check does not
+// set match-bytes.
+const defaultMatchBytes = 1024
+
+type (
+	// fileCheck is an entry of sftp-files, a file whose content is
+	// inspected. Entries are either plain paths or maps carrying the
+	// settings of the check:
+	//
+	//	sftp-files:
+	//	  - /upload/manifest.csv
+	//	  - path: /upload/batch.done
+	//	    match: "^OK"
+	//	    match-bytes: 16
+	//	    max-size: 1024
+	fileCheck struct {
+		Path       string `mapstructure:"path"`
+		Match      string `mapstructure:"match"`
+		MatchBytes int    `mapstructure:"match-bytes"`
+		MaxSize    int64  `mapstructure:"max-size"`
+		pattern    *regexp.Regexp
+	}
+
+	fileContent struct {
+		lines    int
+		checksum string
+		matched  bool
+	}
+)
+
+func loadFileChecks() ([]fileCheck, error) {
+	var entries []any
+	switch value := viper.Get(viperkeys.SFTPFiles).(type) {
+	case nil:
+	case string:
+		for _, path := range strings.Fields(value) {
+			entries = append(entries, path)
+		}
+	case []string:
+		for _, path := range value {
+			entries = append(entries, path)
+		}
+	case []any:
+		entries = value
+	default:
+		return nil, fmt.Errorf("invalid %s: expected a list but got %T", viperkeys.SFTPFiles, value)
+	}
+
+	defaults := fileCheck{
+		MatchBytes: defaultMatchBytes,
+		MaxSize:    viper.GetInt64(viperkeys.SFTPMaxFileSize),
+	}
+	// files are read whole, so there is no way to disable the limit
+	if len(entries) > 0 && defaults.MaxSize <= 0 {
+		return nil, fmt.Errorf("invalid %s: %d is not positive", viperkeys.SFTPMaxFileSize, defaults.MaxSize)
+	}
+	checks := make([]fileCheck, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		check := defaults
+		switch entry := entry.(type) {
+		case string:
+			check.Path = entry
+		case map[string]any:
+			if err := decodeStrict(entry, &check); err != nil {
+				return nil, fmt.Errorf("invalid %s entry %d: %w", viperkeys.SFTPFiles, i, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid %s entry %d: expected a path or a map but got %T", viperkeys.SFTPFiles, i, entry)
+		}
+
+		if check.Path == "" {
+			return nil, fmt.Errorf("invalid %s entry %d: path is empty", viperkeys.SFTPFiles, i)
+		}
+		if seen[check.Path] {
+			return nil, fmt.Errorf("invalid %s entry %d: %s is defined more than once", viperkeys.SFTPFiles, i, check.Path)
+		}
+		seen[check.Path] = true
+		if check.MatchBytes <= 0 {
+			return nil, fmt.Errorf("invalid %s entry %d: match-bytes %d is not positive", viperkeys.SFTPFiles, i, check.MatchBytes)
+		}
+		if check.MaxSize <= 0 {
+			return nil, fmt.Errorf("invalid %s entry %d: max-size %d is not positive", viperkeys.SFTPFiles, i, check.MaxSize)
+		}
+		if check.Match != "" {
+			pattern, err := regexp.Compile(check.Match)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s entry %d: %w", viperkeys.SFTPFiles, i, err)
+			}
+			check.pattern = pattern
+		}
+		checks[i] = check
+	}
+	return checks, nil
+}
+
+// inspect reads the file to count its lines, compute its checksum and match
+// its first bytes. Files larger than the maximum size are not read.
+func (f fileCheck) inspect(ctx context.Context, sftpClient client.SFTPClient) (fileContent, error) {
+	var content fileContent
+	info, err := sftpClient.Stat(f.Path)
+	if err != nil {
+		return content, err
+	}
+	if !info.Mode().IsRegular() {
+		return content, fmt.Errorf("not a regular file")
+	}
+	if info.Size() > f.MaxSize {
+		return content, fmt.Errorf("file size %d exceeds the maximum size %d", info.Size(), f.MaxSize)
+	}
+
+	file, err := sftpClient.Open(f.Path)
+	if err != nil {
+		return content, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.WithFields(log.Fields{"stage": "collecting file metrics", "path": f.Path}).WithError(err).Error("failed to close the file")
+		}
+	}()
+
+	hash := sha256.New()
+	head := make([]byte, 0, f.MatchBytes)
+	buf := make([]byte, 32*1024)
+	var last byte
+	var read int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return content, context.Cause(ctx)
+		}
+		n, err := file.Read(buf)
+		chunk := buf[:n]
+		if read += int64(n); read > f.MaxSize {
+			return content, fmt.Errorf("file grew past the maximum size %d", f.MaxSize)
+		}
+		hash.Write(chunk)
+		content.lines += bytes.Count(chunk, []byte("\n"))
+		if len(head) < f.MatchBytes {
+			head = append(head, chunk[:min(n, f.MatchBytes-len(head))]...)
+		}
+		if n > 0 {
+			last = chunk[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return content, err
+		}
+	}
+
+	// the last line counts even without a trailing newline
+	if read > 0 && last != '\n' {
+		content.lines++
+	}
+	content.checksum = hex.EncodeToString(hash.Sum(nil))
+	if f.pattern != nil {
+		content.matched = f.pattern.Match(head)
+	}
+	return content, nil
+}
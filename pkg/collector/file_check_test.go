@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFileChecks(t *testing.T) {
+	viper.Set(viperkeys.SFTPMaxFileSize, 1024)
+	defer viper.Set(viperkeys.SFTPFiles, nil)
+
+	t.Run("should load files with settings", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPFiles, []any{"/file0", map[string]any{"path": "/file1", "match": "^OK", "match-bytes": 2, "max-size": 16}})
+
+		checks, err := loadFileChecks()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []fileCheck{
+			{Path: "/file0", MatchBytes: defaultMatchBytes, MaxSize: 1024},
+			{Path: "/file1", Match: "^OK", MatchBytes: 2, MaxSize: 16, pattern: regexp.MustCompile("^OK")},
+		}, checks)
+	})
+
+	t.Run("should load files given as environment variable", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPFiles, "/file0 /file1")
+
+		checks, err := loadFileChecks()
+
+		assert.NoError(t, err)
+		assert.Len(t, checks, 2)
+		assert.Equal(t, "/file1", checks[1].Path)
+	})
+
+	t.Run("should return error when pattern is invalid", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPFiles, []any{map[string]any{"path": "/file0", "match": "(OK"}})
+
+		_, err := loadFileChecks()
+
+		assert.ErrorContains(t, err, "invalid sftp-files entry 0: error parsing regexp")
+	})
+
+	t.Run("should return error when path is duplicated", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPFiles, []any{"/file0", map[string]any{"path": "/file0", "match": "^OK"}})
+
+		_, err := loadFileChecks()
+
+		assert.EqualError(t, err, "invalid sftp-files entry 1: /file0 is defined more than once")
+	})
+
+	t.Run("should return error when match-bytes is not positive", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPFiles, []any{map[string]any{"path": "/file0", "match": "^OK", "match-bytes": -1}})
+
+		_, err := loadFileChecks()
+
+		assert.EqualError(t, err, "invalid sftp-files entry 0: match-bytes -1 is not positive")
+	})
+
+	t.Run("should return error when max-size is not positive", func(t *testing.T) {
+		for _, maxSize := range []int{-1, 0} {
+			viper.Set(viperkeys.SFTPFiles, []any{map[string]any{"path": "/file0", "max-size": maxSize}})
+
+			_, err := loadFileChecks()
+
+			assert.EqualError(t, err, fmt.Sprintf("invalid sftp-files entry 0: max-size %d is not positive", maxSize))
+		}
+	})
+
+	t.Run("should return error when sftp-max-file-size is not positive", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPMaxFileSize, 0)
+		defer viper.Set(viperkeys.SFTPMaxFileSize, 1024)
+		viper.Set(viperkeys.SFTPFiles, []string{"/file0"})
+
+		_, err := loadFileChecks()
+
+		assert.EqualError(t, err, "invalid sftp-max-file-size: 0 is not positive")
+	})
+
+	t.Run("should return error when path is missing", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPFiles, []any{map[string]any{"match": "^OK"}})
+
+		_, err := loadFileChecks()
+
+		assert.EqualError(t, err, "invalid sftp-files entry 0: path is empty")
+	})
+}
@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/pkg/sftp"
+)
+
+// filesystem holds the VFS stats of a filesystem along with the
+// configured paths that are found on it.
+type filesystem struct {
+	statVFS *sftp.StatVFS
+	paths   []string
+}
+
+func (f *filesystem) id() string {
+	return strconv.FormatUint(f.statVFS.Fsid, 16)
+}
+
+// path returns the first configured path found on the filesystem, by this
+// scrape or a previous one, which labels its metrics along with the fsid as
+// SFTP does not expose the mount point. The label does not change while that
+// path fails.
+func (f *filesystem) path(configs []pathConfig, fsids map[string]uint64) string {
+	if f.statVFS.Fsid != 0 {
+		for _, config := range configs {
+			if fsid, ok := fsids[config.Path]; ok && fsid == f.statVFS.Fsid {
+				return config.Path
+			}
+		}
+	}
+	return f.paths[0]
+}
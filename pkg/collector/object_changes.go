@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"maps"
+	"time"
+)
+
+type (
+	trackedObject struct {
+		size    int64
+		modTime time.Time
+		// since is when the object was first seen with its size and
+		// modification time
+		since time.Time
+	}
+
+	// trackedObjects holds the objects seen by a walk, up to the limit of
+	// the path. Past the limit the objects are dropped and the walk is not
+	// tracked.
+	trackedObjects struct {
+		objects  map[string]trackedObject
+		limit    int
+		overflow bool
+	}
+
+	// objectChanges counts the objects created, removed and modified in a
+	// path by comparing the objects seen by consecutive walks.
+	objectChanges struct {
+		objects    map[string]trackedObject
+		created    int
+		removed    int
+		modified   int
+		bytesAdded int64
+		overflow   bool
+		// compared tells if the walk of the last scrape was complete and
+		// compared to a previous one, the objects in progress being unknown
+		// otherwise
+		compared bool
+	}
+)
+
+func (t *trackedObjects) add(name string, object trackedObject, limit int) {
+	t.limit = limit
+	if t.overflow {
+		return
+	}
+	if t.objects == nil {
+		t.objects = make(map[string]trackedObject)
+	}
+	t.objects[name] = object
+	t.checkLimit()
+}
+
+func (t *trackedObjects) merge(other trackedObjects) {
+	t.limit = max(t.limit, other.limit)
+	t.overflow = t.overflow || other.overflow
+	if t.overflow || len(other.objects) == 0 {
+		t.checkLimit()
+		return
+	}
+	if t.objects == nil {
+		t.objects = make(map[string]trackedObject, len(other.objects))
+	}
+	maps.Copy(t.objects, other.objects)
+	t.checkLimit()
+}
+
+func (t *trackedObjects) checkLimit() {
+	if t.overflow || len(t.objects) > t.limit {
+		t.overflow = true
+		t.objects = nil
+	}
+}
+
+// update counts the changes since the previous walk. Objects growing count as
+// bytes added. The first walk is only used as the reference of the next one,
+// its objects being taken as unchanged since their modification time.
+func (o *objectChanges) update(objects map[string]trackedObject, now time.Time) {
+	o.compared = o.objects != nil
+	for name, object := range objects {
+		previous, ok := o.objects[name]
+		changed := ok && (previous.size != object.size || !previous.modTime.Equal(object.modTime))
+		object.since = now
+		switch {
+		case !o.compared:
+			if object.modTime.Before(now) {
+				object.since = object.modTime
+			}
+		case ok && !changed:
+			object.since = previous.since
+		}
+		objects[name] = object
+		if o.objects == nil {
+			continue
+		}
+		switch {
+		case !ok:
+			o.created++
+			o.bytesAdded += object.size
+		case changed:
+			o.modified++
+			o.bytesAdded += max(object.size-previous.size, 0)
+		}
+	}
+	for name := range o.objects {
+		if _, ok := objects[name]; !ok {
+			o.removed++
+		}
+	}
+	if objects == nil {
+		objects = make(map[string]trackedObject)
+	}
+	o.objects = objects
+}
+
+// inProgress returns the objects that changed within the stable period, by
+// name. Objects are seen as changing until they kept the same size and
+// modification time for the period across walks.
+func (o *objectChanges) inProgress(now time.Time, stableAfter time.Duration) map[string]trackedObject {
+	objects := make(map[string]trackedObject)
+	for name, object := range o.objects {
+		if now.Sub(object.since) < stableAfter {
+			objects[name] = object
+		}
+	}
+	return objects
+}
+
+// reset drops the objects of the previous walk, so that the next walk is
+// only used as a reference.
+func (o *objectChanges) reset() {
+	o.objects = nil
+	o.compared = false
+}
+
+// setOverflow records if the path has more objects than can be tracked. It
+// returns true when this changed since the previous walk.
+func (o *objectChanges) setOverflow(overflow bool) bool {
+	changed := o.overflow != overflow
+	o.overflow = overflow
+	return changed
+}
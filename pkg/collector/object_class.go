@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// Unclassified is the class of the objects matching none of the rules.
+const Unclassified = "unclassified"
+
+// objectClass is a rule classifying the objects whose name matches one of the
+// patterns separated by "|":
+//
+//	sftp-object-classes:
+//	  - name: invoices
+//	    pattern: "*.xml"
+//	  - name: archives
+//	    pattern: "*.zip|*.gz"
+type objectClass struct {
+	Name    string `mapstructure:"name"`
+	Pattern string `mapstructure:"pattern"`
+}
+
+func (o objectClass) matches(name string) bool {
+	for _, pattern := range strings.Split(o.Pattern, "|") {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (o objectClass) validate() error {
+	if o.Name == "" {
+		return fmt.Errorf("class name is empty")
+	}
+	if o.Name == Unclassified {
+		return fmt.Errorf("class name %q is reserved", Unclassified)
+	}
+	for _, pattern := range strings.Split(o.Pattern, "|") {
+		if pattern == "" {
+			return fmt.Errorf("class %s has an empty pattern", o.Name)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("class %s has an invalid pattern %q: %w", o.Name, pattern, err)
+		}
+	}
+	return nil
+}
+
+// classify returns the name of the first class matching the base name of the
+// object.
+func classify(classes []objectClass, name string) string {
+	name = path.Base(name)
+	for _, class := range classes {
+		if class.matches(name) {
+			return class.Name
+		}
+	}
+	return Unclassified
+}
+
+func loadObjectClasses() ([]objectClass, error) {
+	var classes []objectClass
+	if err := decodeStrict(viper.Get(viperkeys.SFTPObjectClasses), &classes); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", viperkeys.SFTPObjectClasses, err)
+	}
+	if err := validateObjectClasses(classes); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", viperkeys.SFTPObjectClasses, err)
+	}
+	return classes, nil
+}
+
+func validateObjectClasses(classes []objectClass) error {
+	names := make(map[string]bool, len(classes))
+	for _, class := range classes {
+		if err := class.validate(); err != nil {
+			return err
+		}
+		if names[class.Name] {
+			return fmt.Errorf("class %s is defined more than once", class.Name)
+		}
+		names[class.Name] = true
+	}
+	return nil
+}
+
+// decodeStrict decodes the settings, failing on the keys that are not part of
+// the result. Slices of the result are replaced rather than written in place,
+// as they may be shared with the defaults.
+func decodeStrict(input any, result any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:  mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused: true,
+		ZeroFields:  true,
+		Result:      result,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
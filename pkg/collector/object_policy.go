@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/pkg/sftp"
+	"github.com/spf13/viper"
+)
+
+// Rules of the object policy, used as the rule label of the violations.
+const (
+	policyRuleMode = "mode"
+	policyRuleUID  = "uid"
+	policyRuleGID  = "gid"
+)
+
+// otherOwners is the uid or gid of the objects whose owner is beyond the
+// maximum number of owners reported for a path.
+const otherOwners = "other"
+
+// objectPolicy is the expected mode and owner of the objects. Mode holds the
+// permission bits every object must have, in octal. Unset rules are not
+// checked:
+//
+//	sftp-object-policy:
+//	  mode: "0640"
+//	  uid: 1000
+//	  gid: 1000
+type objectPolicy struct {
+	Mode string  `mapstructure:"mode"`
+	UID  *uint32 `mapstructure:"uid"`
+	GID  *uint32 `mapstructure:"gid"`
+	perm os.FileMode
+}
+
+// rules returns the rules that are checked by the policy.
+func (o *objectPolicy) rules() []string {
+	var rules []string
+	if o.Mode != "" {
+		rules = append(rules, policyRuleMode)
+	}
+	if o.UID != nil {
+		rules = append(rules, policyRuleUID)
+	}
+	if o.GID != nil {
+		rules = append(rules, policyRuleGID)
+	}
+	return rules
+}
+
+func (o *objectPolicy) validate() error {
+	if o.Mode == "" {
+		return nil
+	}
+	perm, err := strconv.ParseUint(o.Mode, 8, 32)
+	if err != nil || os.FileMode(perm)&^os.ModePerm != 0 {
+		return fmt.Errorf("policy has an invalid mode %q", o.Mode)
+	}
+	o.perm = os.FileMode(perm)
+	return nil
+}
+
+// violations returns the rules broken by the object. Objects whose owner is
+// unknown are only checked for their mode.
+func (o *objectPolicy) violations(info os.FileInfo) []string {
+	var broken []string
+	if o.Mode != "" && info.Mode().Perm()&o.perm != o.perm {
+		broken = append(broken, policyRuleMode)
+	}
+	stat, ok := info.Sys().(*sftp.FileStat)
+	if !ok {
+		return broken
+	}
+	if o.UID != nil && stat.UID != *o.UID {
+		broken = append(broken, policyRuleUID)
+	}
+	if o.GID != nil && stat.GID != *o.GID {
+		broken = append(broken, policyRuleGID)
+	}
+	return broken
+}
+
+func loadObjectPolicy() (*objectPolicy, error) {
+	var policy *objectPolicy
+	if err := decodeStrict(viper.Get(viperkeys.SFTPObjectPolicy), &policy); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", viperkeys.SFTPObjectPolicy, err)
+	}
+	if policy != nil {
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", viperkeys.SFTPObjectPolicy, err)
+		}
+	}
+	return policy, nil
+}
+
+// topOwners returns the number of objects of the owners with the most objects,
+// the rest of them being added up as other owners.
+func topOwners(owners map[uint32]int, limit int) map[string]int {
+	ids := make([]uint32, 0, len(owners))
+	for id := range owners {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if owners[ids[i]] != owners[ids[j]] {
+			return owners[ids[i]] > owners[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	top := make(map[string]int, min(len(ids), limit+1))
+	for i, id := range ids {
+		if i < limit {
+			top[strconv.FormatUint(uint64(id), 10)] = owners[id]
+		} else {
+			top[otherOwners] += owners[id]
+		}
+	}
+	return top
+}
@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/spf13/viper"
+)
+
+// pathConfig is an entry of sftp-paths. Entries are either plain paths or
+// maps carrying settings for the path, which take precedence over the
+// corresponding global settings. Paths can hold templates of the dates of
+// today and yesterday in the timezone of the path, and glob patterns matched
+// on the server:
+//
+//	sftp-paths:
+//	  - /upload1
+//	  - /users/*/inbox
+//	  - path: /archive/{{ yesterday.Format "2006/01/02" }}
+//	    timezone: Europe/Berlin
+//	  - path: /upload2
+//	    timeout: 5s
+//	    max-entries: 100000
+//	    symlinks: follow
+//	    classes:
+//	      - name: invoices
+//	        pattern: "*.xml"
+//	    policy:
+//	      mode: "0640"
+//	    incremental: true
+//	    max-tracked-objects: 10000
+//	    stable-after: 30s
+//	    recent-windows: [15m, 1h, 24h]
+type pathConfig struct {
+	Path        string        `mapstructure:"path"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	MaxEntries  int           `mapstructure:"max-entries"`
+	Symlinks    string        `mapstructure:"symlinks"`
+	Classes     []objectClass `mapstructure:"classes"`
+	Policy      *objectPolicy `mapstructure:"policy"`
+	Incremental bool          `mapstructure:"incremental"`
+	// MaxTrackedObjects is the number of objects up to which the changes of
+	// the path are tracked
+	MaxTrackedObjects int `mapstructure:"max-tracked-objects"`
+	// StableAfter is how long objects must keep the same size and
+	// modification time to be available
+	StableAfter time.Duration `mapstructure:"stable-after"`
+	// RecentWindows are the windows to count the objects modified within
+	RecentWindows []time.Duration `mapstructure:"recent-windows"`
+	// Timezone is the timezone of the dates of templated paths
+	Timezone string `mapstructure:"timezone"`
+	// pattern is the configured path the path was expanded from, empty for
+	// literal paths
+	pattern string
+}
+
+// Policies for the symbolic links found while walking a path.
+const (
+	// SymlinksSkip ignores the links.
+	SymlinksSkip = "skip"
+	// SymlinksCount counts the links as objects of the size of the link.
+	SymlinksCount = "count"
+	// SymlinksFollow counts the targets of the links in place of the links,
+	// walking the target directories.
+	SymlinksFollow = "follow"
+)
+
+// ValidateConfig checks that the configured paths and files can be parsed.
+func ValidateConfig() error {
+	if _, err := loadPathConfigs(); err != nil {
+		return err
+	}
+	_, err := loadFileChecks()
+	return err
+}
+
+func loadPathConfigs() ([]pathConfig, error) {
+	var entries []any
+	switch value := viper.Get(viperkeys.SFTPPaths).(type) {
+	case nil:
+	case string:
+		for _, path := range strings.Fields(value) {
+			entries = append(entries, path)
+		}
+	case []string:
+		for _, path := range value {
+			entries = append(entries, path)
+		}
+	case []any:
+		entries = value
+	default:
+		return nil, fmt.Errorf("invalid %s: expected a list but got %T", viperkeys.SFTPPaths, value)
+	}
+
+	classes, err := loadObjectClasses()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := loadObjectPolicy()
+	if err != nil {
+		return nil, err
+	}
+	windows, err := loadRecentWindows()
+	if err != nil {
+		return nil, err
+	}
+	defaults := pathConfig{
+		Timeout:           viper.GetDuration(viperkeys.SFTPPathTimeout),
+		MaxEntries:        viper.GetInt(viperkeys.SFTPMaxEntries),
+		Symlinks:          viper.GetString(viperkeys.SFTPSymlinks),
+		Classes:           classes,
+		Policy:            policy,
+		Incremental:       viper.GetBool(viperkeys.SFTPIncremental),
+		MaxTrackedObjects: viper.GetInt(viperkeys.SFTPMaxTrackedObjects),
+		StableAfter:       viper.GetDuration(viperkeys.SFTPStableAfter),
+		RecentWindows:     windows,
+		Timezone:          viper.GetString(viperkeys.SFTPTimezone),
+	}
+	if defaults.Symlinks == "" {
+		defaults.Symlinks = SymlinksCount
+	}
+	configs := make([]pathConfig, len(entries))
+	for i, entry := range entries {
+		config := defaults
+		switch entry := entry.(type) {
+		case string:
+			config.Path = entry
+		case map[string]any:
+			if err := decodeStrict(entry, &config); err != nil {
+				return nil, fmt.Errorf("invalid %s entry %d: %w", viperkeys.SFTPPaths, i, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid %s entry %d: expected a path or a map but got %T", viperkeys.SFTPPaths, i, entry)
+		}
+
+		if config.Path == "" {
+			return nil, fmt.Errorf("invalid %s entry %d: path is empty", viperkeys.SFTPPaths, i)
+		}
+		switch config.Symlinks {
+		case SymlinksSkip, SymlinksCount, SymlinksFollow:
+		default:
+			return nil, fmt.Errorf("invalid %s entry %d: unknown symlinks policy %q", viperkeys.SFTPPaths, i, config.Symlinks)
+		}
+		if err := validateObjectClasses(config.Classes); err != nil {
+			return nil, fmt.Errorf("invalid %s entry %d: %w", viperkeys.SFTPPaths, i, err)
+		}
+		if config.StableAfter > 0 && config.MaxTrackedObjects <= 0 {
+			return nil, fmt.Errorf("invalid %s entry %d: stable-after requires tracking the objects of the path", viperkeys.SFTPPaths, i)
+		}
+		if err := validateRecentWindows(config.RecentWindows); err != nil {
+			return nil, fmt.Errorf("invalid %s entry %d: %w", viperkeys.SFTPPaths, i, err)
+		}
+		if err := config.validatePattern(); err != nil {
+			return nil, fmt.Errorf("invalid %s entry %d: %w", viperkeys.SFTPPaths, i, err)
+		}
+		if config.Policy != nil {
+			if err := config.Policy.validate(); err != nil {
+				return nil, fmt.Errorf("invalid %s entry %d: %w", viperkeys.SFTPPaths, i, err)
+			}
+		}
+		configs[i] = config
+	}
+	return configs, nil
+}
+
+func loadRecentWindows() ([]time.Duration, error) {
+	var windows []time.Duration
+	for _, value := range viper.GetStringSlice(viperkeys.SFTPRecentWindows) {
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", viperkeys.SFTPRecentWindows, err)
+		}
+		windows = append(windows, window)
+	}
+	if err := validateRecentWindows(windows); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", viperkeys.SFTPRecentWindows, err)
+	}
+	return windows, nil
+}
+
+func validateRecentWindows(windows []time.Duration) error {
+	seen := make(map[time.Duration]bool, len(windows))
+	for _, window := range windows {
+		if window <= 0 {
+			return fmt.Errorf("recent window %s is not positive", window)
+		}
+		if seen[window] {
+			return fmt.Errorf("recent window %s is duplicated", window)
+		}
+		seen[window] = true
+	}
+	return nil
+}
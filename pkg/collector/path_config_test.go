@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPathConfigs(t *testing.T) {
+	tests := []struct {
+		desc    string
+		paths   any
+		configs []pathConfig
+		err     error
+	}{
+		{
+			desc:    "should load paths given as flags",
+			paths:   []string{"/path0", "/path1"},
+			configs: []pathConfig{{Path: "/path0", Timeout: time.Minute, Symlinks: SymlinksCount}, {Path: "/path1", Timeout: time.Minute, Symlinks: SymlinksCount}},
+		},
+		{
+			desc:    "should load paths given as environment variable",
+			paths:   "/path0 /path1",
+			configs: []pathConfig{{Path: "/path0", Timeout: time.Minute, Symlinks: SymlinksCount}, {Path: "/path1", Timeout: time.Minute, Symlinks: SymlinksCount}},
+		},
+		{
+			desc:    "should load paths with settings",
+			paths:   []any{"/path0", map[string]any{"path": "/path1", "timeout": "5s"}},
+			configs: []pathConfig{{Path: "/path0", Timeout: time.Minute, Symlinks: SymlinksCount}, {Path: "/path1", Timeout: 5 * time.Second, Symlinks: SymlinksCount}},
+		},
+		{
+			desc:    "should load symlinks policy of the path",
+			paths:   []any{map[string]any{"path": "/path0", "symlinks": "follow"}},
+			configs: []pathConfig{{Path: "/path0", Timeout: time.Minute, Symlinks: SymlinksFollow}},
+		},
+		{
+			desc:  "should return error when symlinks policy is unknown",
+			paths: []any{map[string]any{"path": "/path0", "symlinks": "resolve"}},
+			err:   fmt.Errorf(`invalid sftp-paths entry 0: unknown symlinks policy "resolve"`),
+		},
+		{
+			desc: "should load object classes of the path",
+			paths: []any{map[string]any{"path": "/path0", "classes": []any{
+				map[string]any{"name": "archives", "pattern": "*.zip|*.gz"},
+			}}},
+			configs: []pathConfig{{Path: "/path0", Timeout: time.Minute, Symlinks: SymlinksCount,
+				Classes: []objectClass{{Name: "archives", Pattern: "*.zip|*.gz"}}}},
+		},
+		{
+			desc: "should return error when object class pattern is invalid",
+			paths: []any{map[string]any{"path": "/path0", "classes": []any{
+				map[string]any{"name": "archives", "pattern": "*.zip|[.gz"},
+			}}},
+			err: fmt.Errorf(`invalid sftp-paths entry 0: %w`,
+				fmt.Errorf(`class archives has an invalid pattern "[.gz": %w`, path.ErrBadPattern)),
+		},
+		{
+			desc:  "should return error when policy mode is invalid",
+			paths: []any{map[string]any{"path": "/path0", "policy": map[string]any{"mode": "0980"}}},
+			err:   fmt.Errorf(`invalid sftp-paths entry 0: %w`, fmt.Errorf(`policy has an invalid mode "0980"`)),
+		},
+		{
+			desc:  "should return error when stable-after is set without tracking objects",
+			paths: []any{map[string]any{"path": "/path0", "stable-after": "30s"}},
+			err:   fmt.Errorf("invalid sftp-paths entry 0: stable-after requires tracking the objects of the path"),
+		},
+		{
+			desc:    "should load recent windows of the path",
+			paths:   []any{map[string]any{"path": "/path0", "recent-windows": []any{"15m", "1h"}}},
+			configs: []pathConfig{{Path: "/path0", Timeout: time.Minute, Symlinks: SymlinksCount, RecentWindows: []time.Duration{15 * time.Minute, time.Hour}}},
+		},
+		{
+			desc:  "should return error when recent window is duplicated",
+			paths: []any{map[string]any{"path": "/path0", "recent-windows": []any{"1h", "60m"}}},
+			err:   fmt.Errorf(`invalid sftp-paths entry 0: %w`, fmt.Errorf("recent window 1h0m0s is duplicated")),
+		},
+		{
+			desc:    "should load templated paths with their timezone",
+			paths:   []any{map[string]any{"path": `/archive/{{ today.Format "2006/01/02" }}`, "timezone": "Europe/Berlin"}},
+			configs: []pathConfig{{Path: `/archive/{{ today.Format "2006/01/02" }}`, Timeout: time.Minute, Symlinks: SymlinksCount, Timezone: "Europe/Berlin"}},
+		},
+		{
+			desc:  "should return error when timezone is unknown",
+			paths: []any{map[string]any{"path": "/path0", "timezone": "Mars/Olympus"}},
+			err:   fmt.Errorf(`invalid sftp-paths entry 0: %w`, fmt.Errorf(`unknown timezone "Mars/Olympus"`)),
+		},
+		{
+			desc:  "should return error when glob pattern is invalid",
+			paths: []string{"/users/[a-]/inbox"},
+			err: fmt.Errorf(`invalid sftp-paths entry 0: %w`,
+				fmt.Errorf(`path has an invalid pattern: %w`, path.ErrBadPattern)),
+		},
+		{
+			desc:    "should accept brackets without a closing one as literal",
+			paths:   []string{"/data/[archive"},
+			configs: []pathConfig{{Path: "/data/[archive", Timeout: time.Minute, Symlinks: SymlinksCount}},
+		},
+		{
+			desc:  "should return error when path is missing",
+			paths: []any{map[string]any{"timeout": "5s"}},
+			err:   fmt.Errorf("invalid sftp-paths entry 0: path is empty"),
+		},
+		{
+			desc:  "should return error when entry is neither a path nor a map",
+			paths: []any{"/path0", 1},
+			err:   fmt.Errorf("invalid sftp-paths entry 1: expected a path or a map but got int"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			viper.Set(viperkeys.SFTPPathTimeout, time.Minute)
+			viper.Set(viperkeys.SFTPPaths, test.paths)
+			viper.Set(viperkeys.SFTPObjectClasses, nil)
+			viper.Set(viperkeys.SFTPObjectPolicy, nil)
+
+			configs, err := loadPathConfigs()
+
+			assert.Equal(t, test.configs, configs)
+			assert.Equal(t, test.err, err)
+		})
+	}
+
+	t.Run("should return error when setting is unknown", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPPaths, []any{map[string]any{"path": "/path0", "timeot": "5s"}})
+
+		_, err := loadPathConfigs()
+
+		assert.ErrorContains(t, err, "invalid sftp-paths entry 0")
+		assert.ErrorContains(t, err, "timeot")
+	})
+
+	t.Run("should return error when recent window is invalid", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+		viper.Set(viperkeys.SFTPRecentWindows, []string{"1h", "1 day"})
+		defer viper.Set(viperkeys.SFTPRecentWindows, nil)
+
+		_, err := loadPathConfigs()
+
+		assert.ErrorContains(t, err, `invalid sftp-recent-windows: time: unknown unit " day"`)
+	})
+
+	t.Run("should return error when path template is invalid", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPPaths, []string{"/archive/{{ tomorrow }}"})
+
+		_, err := loadPathConfigs()
+
+		assert.ErrorContains(t, err, `invalid sftp-paths entry 0: template: path:1: function "tomorrow" not defined`)
+	})
+
+	t.Run("should apply object classes to all the paths", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+		viper.Set(viperkeys.SFTPObjectClasses, []any{map[string]any{"name": "invoices", "pattern": "*.xml"}})
+		defer viper.Set(viperkeys.SFTPObjectClasses, nil)
+
+		configs, err := loadPathConfigs()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []objectClass{{Name: "invoices", Pattern: "*.xml"}}, configs[0].Classes)
+	})
+
+	t.Run("should return error when object class is reserved", func(t *testing.T) {
+		viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+		viper.Set(viperkeys.SFTPObjectClasses, []any{map[string]any{"name": "unclassified", "pattern": "*"}})
+		defer viper.Set(viperkeys.SFTPObjectClasses, nil)
+
+		_, err := loadPathConfigs()
+
+		assert.EqualError(t, err, `invalid sftp-object-classes: class name "unclassified" is reserved`)
+	})
+}
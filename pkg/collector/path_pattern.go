@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/client"
+)
+
+// isTemplate tells if the path holds template actions, like
+// /archive/{{ today.Format "2006/01/02" }}.
+func isTemplate(p string) bool {
+	return strings.Contains(p, "{{")
+}
+
+// isGlob tells if the path holds the special characters of glob patterns,
+// like /users/*/inbox. Characters escaped by a backslash and brackets without
+// a closing one are literal, like /data/\[archive] or /data/[archive.
+func isGlob(p string) bool {
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '*', '?':
+			return true
+		case '[':
+			if strings.Contains(p[i+1:], "]") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unescape removes the backslashes escaping the characters of the literal
+// path.
+func unescape(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] == '\\' && i+1 < len(p) {
+			i++
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
+
+// pathTemplate parses the path as a template whose today and yesterday
+// functions return the dates as of now.
+func pathTemplate(p string, now time.Time) (*template.Template, error) {
+	return template.New("path").Funcs(template.FuncMap{
+		"today":     func() time.Time { return now },
+		"yesterday": func() time.Time { return now.AddDate(0, 0, -1) },
+	}).Parse(p)
+}
+
+// validatePattern checks that the templates and glob patterns of the path can
+// be parsed.
+func (c pathConfig) validatePattern() error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", c.Timezone)
+	}
+	if isTemplate(c.Path) {
+		_, err := pathTemplate(c.Path, time.Now())
+		return err
+	}
+	if !isGlob(c.Path) {
+		return nil
+	}
+	if _, err := path.Match(c.Path, ""); err != nil {
+		return fmt.Errorf("path has an invalid pattern: %w", err)
+	}
+	return nil
+}
+
+// expand returns the configs of the concrete paths of the config, executing
+// its template with the dates of now in the timezone of the path and globbing
+// the result, unless ctx expires first. Literal paths are returned as they are
+// once unescaped.
+func (c pathConfig) expand(ctx context.Context, sftpClient client.SFTPClient, now time.Time) ([]pathConfig, error) {
+	if !isTemplate(c.Path) && !isGlob(c.Path) {
+		c.Path = unescape(c.Path)
+		return []pathConfig{c}, nil
+	}
+
+	concrete := c.Path
+	if isTemplate(c.Path) {
+		location, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := pathTemplate(c.Path, now.In(location))
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, nil); err != nil {
+			return nil, err
+		}
+		concrete = b.String()
+	}
+
+	paths := []string{unescape(concrete)}
+	if isGlob(concrete) {
+		matches, err := callWithContext(ctx, func() ([]string, error) {
+			return sftpClient.Glob(concrete)
+		})
+		if err != nil {
+			return nil, err
+		}
+		paths = matches
+	}
+
+	configs := make([]pathConfig, len(paths))
+	for i, p := range paths {
+		configs[i] = c
+		configs[i].Path = p
+		configs[i].pattern = c.Path
+	}
+	return configs, nil
+}
@@ -1,12 +1,18 @@
 package collector
 
 import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
 	"github.com/arunvelsriram/sftp-exporter/pkg/client"
 	c "github.com/arunvelsriram/sftp-exporter/pkg/constants"
+	"github.com/pkg/sftp"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -20,108 +26,667 @@ var (
 
 	fsTotalSpace = prometheus.NewDesc(
 		prometheus.BuildFQName(c.Namespace, "", "filesystem_total_space_bytes"),
-		"Total space in the filesystem containing the path",
-		[]string{"path"},
+		"Total space in the filesystem",
+		[]string{"fsid", "path"},
 		nil,
 	)
 
 	fsFreeSpace = prometheus.NewDesc(
 		prometheus.BuildFQName(c.Namespace, "", "filesystem_free_space_bytes"),
-		"Free space in the filesystem containing the path",
-		[]string{"path"},
+		"Free space in the filesystem",
+		[]string{"fsid", "path"},
+		nil,
+	)
+
+	pathFilesystem = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "path_filesystem_info"),
+		"Maps the path to the filesystem containing it",
+		[]string{"path", "fsid"},
 		nil,
 	)
 
 	objectCount = prometheus.NewDesc(
 		prometheus.BuildFQName(c.Namespace, "", "objects_available"),
-		"Number of objects in the path",
-		[]string{"path"},
+		"Number of objects in the path, by class when the path has classes",
+		[]string{"path", "class"},
 		nil,
 	)
 
 	objectSize = prometheus.NewDesc(
 		prometheus.BuildFQName(c.Namespace, "", "objects_total_size_bytes"),
-		"Total size of all the objects in the path",
+		"Total size of all the objects in the path, by class when the path has classes",
+		[]string{"path", "class"},
+		nil,
+	)
+
+	objectsInProgress = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "objects_in_progress"),
+		"Number of objects in the path that are still changing, which are not counted as available",
+		[]string{"path"},
+		nil,
+	)
+
+	objectsRecent = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "objects_recent"),
+		"Number of objects in the path modified within the window",
+		[]string{"path", "window"},
+		nil,
+	)
+
+	objectsTruncated = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "objects_truncated"),
+		"Tells if walking the path stopped at the maximum number of entries, making the object metrics lower bounds",
+		[]string{"path"},
+		nil,
+	)
+
+	ownerObjectCount = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "owner_objects_available"),
+		"Number of objects in the path by owner uid",
+		[]string{"path", "uid"},
+		nil,
+	)
+
+	groupObjectCount = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "group_objects_available"),
+		"Number of objects in the path by group gid",
+		[]string{"path", "gid"},
+		nil,
+	)
+
+	policyViolations = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "objects_policy_violations"),
+		"Number of objects in the path breaking the rule of the expected mode and owner",
+		[]string{"path", "rule"},
+		nil,
+	)
+
+	directoryCount = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "directories_available"),
+		"Number of directories in the path",
+		[]string{"path"},
+		nil,
+	)
+
+	emptyDirectories = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "empty_directories"),
+		"Number of empty directories in the path",
+		[]string{"path"},
+		nil,
+	)
+
+	symlinksBroken = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "symlinks_broken"),
+		"Number of symbolic links in the path whose target does not exist",
+		[]string{"path"},
+		nil,
+	)
+
+	directoryCacheHitRatio = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "directory_cache_hit_ratio"),
+		"Ratio of the directories of the path whose listing was reused from the previous walk",
+		[]string{"path"},
+		nil,
+	)
+
+	objectsCreated = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "objects_created_total"),
+		"Number of objects created in the path",
+		[]string{"path"},
+		nil,
+	)
+
+	objectsRemoved = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "objects_removed_total"),
+		"Number of objects removed from the path",
+		[]string{"path"},
+		nil,
+	)
+
+	objectsModified = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "objects_modified_total"),
+		"Number of times objects of the path changed in size or modification time",
+		[]string{"path"},
+		nil,
+	)
+
+	bytesAdded = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "bytes_added_total"),
+		"Number of bytes added to the path by created and growing objects",
+		[]string{"path"},
+		nil,
+	)
+
+	pathExists = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "path_exists"),
+		"Tells if the path exists",
+		[]string{"path"},
+		nil,
+	)
+
+	pathCollectTimeout = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "path_collect_timeout"),
+		"Tells if collecting the object metrics of the path timed out",
+		[]string{"path"},
+		nil,
+	)
+
+	pathPattern = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "path_pattern_info"),
+		"Maps the path to the configured pattern it was expanded from",
+		[]string{"pattern", "path"},
+		nil,
+	)
+
+	fileLines = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "file_lines"),
+		"Number of lines in the file",
+		[]string{"path"},
+		nil,
+	)
+
+	fileChecksum = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "file_checksum_info"),
+		"SHA-256 checksum of the file",
+		[]string{"path", "sha256"},
+		nil,
+	)
+
+	fileContentMatch = prometheus.NewDesc(
+		prometheus.BuildFQName(c.Namespace, "", "file_content_match"),
+		"Tells if the first bytes of the file match the pattern",
 		[]string{"path"},
 		nil,
 	)
 )
 
-type SFTPCollector struct {
-	sftpClient client.SFTPClient
-}
+type (
+	SFTPCollector struct {
+		sftpClient client.SFTPClient
+		// sem allows a single collection at a time, as the SFTP client
+		// holds one connection that is opened and closed by every collection.
+		// The state below is only accessed while holding it.
+		sem            chan struct{}
+		truncatedPaths map[string]bool
+		dirCaches      map[string]*dirCache
+		changes        map[string]*objectChanges
+		closed         bool
+		// fsids holds the fsid each path was last found on, for filesystems
+		// to keep their label while a path fails
+		fsids map[string]uint64
+		// statusMu guards the status, which is read while collecting
+		statusMu sync.Mutex
+		status   TargetStatus
+	}
+
+	contextCollector struct {
+		*SFTPCollector
+		ctx context.Context
+	}
+)
 
-func (s SFTPCollector) Describe(ch chan<- *prometheus.Desc) {
+func (s *SFTPCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- up
 	useStatVfs := viper.GetBool(viperkeys.SFTPStatVfs)
 	if useStatVfs {
 		ch <- fsTotalSpace
 		ch <- fsFreeSpace
+		ch <- pathFilesystem
 	}
 	ch <- objectCount
 	ch <- objectSize
+	ch <- objectsInProgress
+	ch <- objectsRecent
+	ch <- objectsTruncated
+	ch <- ownerObjectCount
+	ch <- groupObjectCount
+	ch <- policyViolations
+	ch <- directoryCount
+	ch <- emptyDirectories
+	ch <- symlinksBroken
+	ch <- directoryCacheHitRatio
+	ch <- objectsCreated
+	ch <- objectsRemoved
+	ch <- objectsModified
+	ch <- bytesAdded
+	ch <- pathExists
+	ch <- pathCollectTimeout
+	ch <- pathPattern
+	ch <- fileLines
+	ch <- fileChecksum
+	ch <- fileContentMatch
+}
+
+// Close waits for the collection in progress to close its SFTP connection, and
+// stops any further collection.
+func (s *SFTPCollector) Close(ctx context.Context) error {
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.closed = true
+	return nil
+}
+
+func (s *SFTPCollector) Collect(ch chan<- prometheus.Metric) {
+	s.CollectWithContext(context.Background(), ch)
 }
 
-func (s SFTPCollector) Collect(ch chan<- prometheus.Metric) {
-	paths := viper.GetStringSlice(viperkeys.SFTPPaths)
+// WithContext returns a collector whose collections stop when ctx expires.
+func (s *SFTPCollector) WithContext(ctx context.Context) prometheus.Collector {
+	return contextCollector{SFTPCollector: s, ctx: ctx}
+}
+
+func (c contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectWithContext(c.ctx, ch)
+}
+
+// CollectWithContext writes the metrics of the paths that could be collected
+// before ctx expires, along with the timeout metric for the rest of them.
+func (s *SFTPCollector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	configs, err := loadPathConfigs()
+	if err != nil {
+		log.WithField("stage", "loading path configs").WithError(err).Error("invalid path configs")
+		return
+	}
+	fileChecks, err := loadFileChecks()
+	if err != nil {
+		log.WithField("stage", "loading file checks").WithError(err).Error("invalid file checks")
+		return
+	}
+	if timeout := viper.GetDuration(viperkeys.ScrapeTimeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	case <-ctx.Done():
+		log.WithField("stage", "waiting for previous collection").WithError(ctx.Err()).Error("scrape timed out")
+		return
+	}
+	target := client.Target()
+	if s.closed {
+		log.WithFields(log.Fields{"stage": "collecting up metric", "target": target}).Warn("collector is closed")
+		return
+	}
 
 	if err := s.sftpClient.Connect(); err != nil {
+		s.recordConnection(target, err, time.Now())
 		ch <- prometheus.MustNewConstMetric(up, prometheus.GaugeValue, 0)
-		log.WithField("when", "collecting up metric").Error(err)
+		log.WithFields(log.Fields{"stage": "collecting up metric", "target": target}).WithError(err).Error("failed to connect to SFTP")
 		return
 	}
 	defer func() {
 		if err := s.sftpClient.Close(); err != nil {
-			log.WithField("when", "closing sftp client").Error(err)
+			log.WithFields(log.Fields{"stage": "closing sftp client", "target": target}).WithError(err).Error("failed to close the connection")
 		}
 	}()
-	log.Debug("connected to SFTP")
+	s.recordConnection(target, nil, time.Now())
+	log.WithField("target", target).Debug("connected to SFTP")
 	ch <- prometheus.MustNewConstMetric(up, prometheus.GaugeValue, 1)
 
+	configs = s.expandPaths(ctx, ch, configs, time.Now())
+
 	useStatVfs := viper.GetBool(viperkeys.SFTPStatVfs)
 	if useStatVfs {
 		log.Debug("collecting filesystem metrics")
-		for _, path := range paths {
-			log.Debugf("collecting filesystem metrics for path: %s", path)
-			statVFS, err := s.sftpClient.StatVFS(path)
-			if err != nil {
-				log.WithFields(log.Fields{"when": "collecting filesystem metrics", "path": path}).Error(err)
+		s.collectFilesystemMetrics(ctx, ch, configs)
+	}
+
+	log.Debug("collecting object metrics")
+	caches := s.dirCachesOf(configs)
+	walks := walkPaths(ctx, s.sftpClient, configs, caches, viper.GetInt(viperkeys.SFTPMaxConcurrency))
+	s.trackChanges(walks, time.Now())
+	paths := make([]PathStatus, len(walks))
+	for i, walk := range walks {
+		path := walk.config.Path
+		result := walk.result()
+		paths[i] = newPathStatus(walk.config, result)
+		if walk.cache != nil {
+			walk.cache.commit(result.err == nil && !result.truncated)
+		}
+		if result.err == nil {
+			s.logTruncation(walk.config, result.truncated)
+			// objects are only available once they stopped changing, which
+			// is known once the path was walked twice
+			var inProgress map[string]trackedObject
+			if state, ok := s.changes[path]; ok && walk.config.StableAfter > 0 && state.compared {
+				inProgress = state.inProgress(time.Now(), walk.config.StableAfter)
+				ch <- prometheus.MustNewConstMetric(objectsInProgress, prometheus.GaugeValue, float64(len(inProgress)), path)
+			}
+			s.collectAvailableMetrics(ch, walk.config, result.stats, inProgress)
+			for _, window := range walk.config.RecentWindows {
+				recent := result.stats.recentCount(time.Now(), window)
+				ch <- prometheus.MustNewConstMetric(objectsRecent, prometheus.GaugeValue, float64(recent), path, windowLabel(window))
+			}
+			ch <- prometheus.MustNewConstMetric(objectsTruncated, prometheus.GaugeValue, boolToFloat64(result.truncated), path)
+			s.collectOwnerMetrics(ch, walk.config, result.stats)
+			ch <- prometheus.MustNewConstMetric(directoryCount, prometheus.GaugeValue, float64(result.stats.directoryCount), path)
+			ch <- prometheus.MustNewConstMetric(emptyDirectories, prometheus.GaugeValue, float64(result.stats.emptyDirCount), path)
+			if walk.config.Symlinks != SymlinksSkip {
+				ch <- prometheus.MustNewConstMetric(symlinksBroken, prometheus.GaugeValue, float64(result.stats.symlinksBroken), path)
+			}
+			if walk.cache != nil {
+				ch <- prometheus.MustNewConstMetric(directoryCacheHitRatio, prometheus.GaugeValue, walk.cache.hitRatio(), path)
+			}
+			ch <- prometheus.MustNewConstMetric(pathExists, prometheus.GaugeValue, 1, path)
+		} else if result.missing {
+			ch <- prometheus.MustNewConstMetric(pathExists, prometheus.GaugeValue, 0, path)
+		}
+		ch <- prometheus.MustNewConstMetric(pathCollectTimeout, prometheus.GaugeValue, boolToFloat64(result.timedOut), path)
+		s.collectChangeMetrics(ch, path)
+	}
+
+	s.recordPaths(paths)
+
+	if len(fileChecks) > 0 {
+		log.Debug("collecting file metrics")
+		s.collectFileMetrics(ctx, ch, fileChecks)
+	}
+	if ctx.Err() == nil {
+		s.recordSuccess(time.Now())
+	}
+}
+
+// expandPaths returns the configs of the concrete paths of the configs, writing
+// the pattern each of them was expanded from. Paths already expanded from a
+// previous entry are skipped.
+func (s *SFTPCollector) expandPaths(ctx context.Context, ch chan<- prometheus.Metric, configs []pathConfig, now time.Time) []pathConfig {
+	expanded := make([]pathConfig, 0, len(configs))
+	seen := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		fields := log.Fields{"stage": "expanding paths", "path": config.Path}
+		concrete, err := config.expand(ctx, s.sftpClient, now)
+		if err != nil {
+			log.WithFields(fields).WithError(err).Error("failed to expand the path")
+			continue
+		}
+		if len(concrete) == 0 {
+			log.WithFields(fields).Debug("pattern matches no path")
+		}
+		for _, c := range concrete {
+			if seen[c.Path] {
+				log.WithFields(fields).Warnf("skipping %s, already collected for a previous entry", c.Path)
+				continue
+			}
+			seen[c.Path] = true
+			expanded = append(expanded, c)
+			if c.pattern != "" {
+				ch <- prometheus.MustNewConstMetric(pathPattern, prometheus.GaugeValue, 1, c.pattern, c.Path)
+			}
+		}
+	}
+	return expanded
+}
+
+// collectFileMetrics writes the content metrics of the files that could be
+// read.
+func (s *SFTPCollector) collectFileMetrics(ctx context.Context, ch chan<- prometheus.Metric, checks []fileCheck) {
+	for _, check := range checks {
+		fields := log.Fields{"stage": "collecting file metrics", "path": check.Path}
+		content, err := callWithContext(ctx, func() (fileContent, error) {
+			return check.inspect(ctx, s.sftpClient)
+		})
+		if err != nil {
+			log.WithFields(fields).WithError(err).Error("failed to inspect the file")
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(fileLines, prometheus.GaugeValue, float64(content.lines), check.Path)
+		ch <- prometheus.MustNewConstMetric(fileChecksum, prometheus.GaugeValue, 1, check.Path, content.checksum)
+		if check.pattern != nil {
+			ch <- prometheus.MustNewConstMetric(fileContentMatch, prometheus.GaugeValue, boolToFloat64(content.matched), check.Path)
+		}
+	}
+}
+
+// trackChanges counts the changes of the paths whose walk is complete,
+// dropping the state of the paths that are no longer tracked.
+func (s *SFTPCollector) trackChanges(walks []*pathWalk, now time.Time) {
+	changes := make(map[string]*objectChanges)
+	for _, walk := range walks {
+		config := walk.config
+		if config.MaxTrackedObjects <= 0 {
+			continue
+		}
+		state, ok := s.changes[config.Path]
+		if !ok {
+			state = &objectChanges{}
+		}
+		changes[config.Path] = state
+
+		result := walk.result()
+		tracked := result.stats.tracked
+		if result.err != nil || result.truncated {
+			// the objects in progress are unknown without a complete walk
+			state.compared = false
+			continue
+		}
+		fields := log.Fields{"stage": "collecting change metrics", "path": config.Path}
+		if state.setOverflow(tracked.overflow) {
+			if tracked.overflow {
+				log.WithFields(fields).Warnf("path has more than %d objects, changes are not tracked", config.MaxTrackedObjects)
 			} else {
-				totalSpace := float64(statVFS.TotalSpace())
-				freeSpace := float64(statVFS.FreeSpace())
-				log.Debugf("writing filesystem metrics for path: %s", path)
-				ch <- prometheus.MustNewConstMetric(fsTotalSpace, prometheus.GaugeValue, totalSpace, path)
-				ch <- prometheus.MustNewConstMetric(fsFreeSpace, prometheus.GaugeValue, freeSpace, path)
+				log.WithFields(fields).Infof("path is within %d objects again, tracking changes", config.MaxTrackedObjects)
 			}
 		}
+		if tracked.overflow {
+			state.reset()
+		} else {
+			state.update(tracked.objects, now)
+		}
 	}
+	s.changes = changes
+}
 
-	log.Debug("collecting object metrics")
-	for _, path := range paths {
-		log.Debugf("collecting object metrics for path: %s", path)
-		var size int64
-		count := 0
-		var walkErr error
-		walker := s.sftpClient.Walk(path)
-		for walker.Step() {
-			if walkErr = walker.Err(); walkErr != nil {
-				log.WithFields(log.Fields{"when": "collecting object metrics", "path": path}).Error(walkErr)
+// collectChangeMetrics writes the change counters of the path, if tracked.
+func (s *SFTPCollector) collectChangeMetrics(ch chan<- prometheus.Metric, path string) {
+	state, ok := s.changes[path]
+	if !ok {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(objectsCreated, prometheus.CounterValue, float64(state.created), path)
+	ch <- prometheus.MustNewConstMetric(objectsRemoved, prometheus.CounterValue, float64(state.removed), path)
+	ch <- prometheus.MustNewConstMetric(objectsModified, prometheus.CounterValue, float64(state.modified), path)
+	ch <- prometheus.MustNewConstMetric(bytesAdded, prometheus.CounterValue, float64(state.bytesAdded), path)
+}
+
+// dirCachesOf returns the caches of the paths walked incrementally, dropping
+// the caches of the other paths and the ones whose settings changed.
+func (s *SFTPCollector) dirCachesOf(configs []pathConfig) map[string]*dirCache {
+	caches := make(map[string]*dirCache)
+	for _, config := range configs {
+		if !config.Incremental {
+			continue
+		}
+		cache, ok := s.dirCaches[config.Path]
+		if !ok || !cache.matches(config) {
+			cache = newDirCache(config)
+		}
+		cache.begin()
+		caches[config.Path] = cache
+	}
+	s.dirCaches = caches
+	return caches
+}
+
+// collectAvailableMetrics writes the number and size of the available objects
+// of the path, by class when the path has classes, including the classes
+// without objects. The class is empty otherwise, which Prometheus takes as no
+// class at all. Objects in progress are not available.
+func (s *SFTPCollector) collectAvailableMetrics(ch chan<- prometheus.Metric, config pathConfig, stats objectStats,
+	inProgress map[string]trackedObject) {
+	names := []string{""}
+	classes := map[string]classStats{"": {count: stats.count, size: stats.size}}
+	if len(config.Classes) > 0 {
+		names = names[:0]
+		for _, class := range config.Classes {
+			names = append(names, class.Name)
+		}
+		names = append(names, Unclassified)
+		classes = stats.classes
+	}
+	available := make(map[string]classStats, len(names))
+	for _, name := range names {
+		available[name] = classes[name]
+	}
+	for name, object := range inProgress {
+		var class string
+		if len(config.Classes) > 0 {
+			class = classify(config.Classes, name)
+		}
+		available[class] = classStats{count: available[class].count - 1, size: available[class].size - object.size}
+	}
+	for _, name := range names {
+		ch <- prometheus.MustNewConstMetric(objectCount, prometheus.GaugeValue, float64(available[name].count), config.Path, name)
+		ch <- prometheus.MustNewConstMetric(objectSize, prometheus.GaugeValue, float64(available[name].size), config.Path, name)
+	}
+}
+
+func (s *SFTPCollector) collectOwnerMetrics(ch chan<- prometheus.Metric, config pathConfig, stats objectStats) {
+	maxOwners := viper.GetInt(viperkeys.SFTPMaxOwners)
+	for uid, count := range topOwners(stats.uids, maxOwners) {
+		ch <- prometheus.MustNewConstMetric(ownerObjectCount, prometheus.GaugeValue, float64(count), config.Path, uid)
+	}
+	for gid, count := range topOwners(stats.gids, maxOwners) {
+		ch <- prometheus.MustNewConstMetric(groupObjectCount, prometheus.GaugeValue, float64(count), config.Path, gid)
+	}
+	if config.Policy != nil {
+		for _, rule := range config.Policy.rules() {
+			ch <- prometheus.MustNewConstMetric(policyViolations, prometheus.GaugeValue, float64(stats.violations[rule]), config.Path, rule)
+		}
+	}
+}
+
+// logTruncation logs when a path starts or stops getting truncated rather
+// than on every collection.
+func (s *SFTPCollector) logTruncation(config pathConfig, truncated bool) {
+	fields := log.Fields{"stage": "collecting object metrics", "path": config.Path}
+	if truncated && !s.truncatedPaths[config.Path] {
+		log.WithFields(fields).Warnf("path has more than %d entries, object metrics are lower bounds", config.MaxEntries)
+		s.truncatedPaths[config.Path] = true
+	} else if !truncated && s.truncatedPaths[config.Path] {
+		log.WithFields(fields).Infof("path is within %d entries again", config.MaxEntries)
+		delete(s.truncatedPaths, config.Path)
+	}
+}
+
+// collectFilesystemMetrics groups the paths by the filesystem containing them
+// so that the space metrics are written once per filesystem. Paths are not
+// grouped when the server does not report the fsid.
+func (s *SFTPCollector) collectFilesystemMetrics(ctx context.Context, ch chan<- prometheus.Metric, configs []pathConfig) {
+	var filesystems []*filesystem
+	byFsid := make(map[uint64]*filesystem)
+	fsids := make(map[string]uint64, len(configs))
+	for _, config := range configs {
+		if fsid, ok := s.fsids[config.Path]; ok {
+			fsids[config.Path] = fsid
+		}
+	}
+	s.fsids = fsids
+	for _, config := range configs {
+		path := config.Path
+		if err := ctx.Err(); err != nil {
+			log.WithFields(log.Fields{"stage": "collecting filesystem metrics", "path": path}).WithError(err).Error("scrape timed out")
+			break
+		}
+
+		log.WithField("path", path).Debug("collecting filesystem metrics")
+		statVFS, err := callWithContext(ctx, func() (*sftp.StatVFS, error) {
+			return s.sftpClient.StatVFS(path)
+		})
+		if err != nil {
+			log.WithFields(log.Fields{"stage": "collecting filesystem metrics", "path": path}).WithError(err).Error("failed to get the filesystem stats")
+			if ctx.Err() != nil {
 				break
 			}
+			continue
+		}
 
-			if walker.Stat().IsDir() {
-				continue
+		fs, ok := byFsid[statVFS.Fsid]
+		if !ok || statVFS.Fsid == 0 {
+			fs = &filesystem{statVFS: statVFS}
+			if statVFS.Fsid != 0 {
+				byFsid[statVFS.Fsid] = fs
 			}
-			size += walker.Stat().Size()
-			count++
+			filesystems = append(filesystems, fs)
 		}
-		if walkErr == nil {
-			ch <- prometheus.MustNewConstMetric(objectCount, prometheus.GaugeValue, float64(count), path)
-			ch <- prometheus.MustNewConstMetric(objectSize, prometheus.GaugeValue, float64(size), path)
+		fs.paths = append(fs.paths, path)
+		fsids[path] = statVFS.Fsid
+	}
+
+	for _, fs := range filesystems {
+		fsid, label := fs.id(), fs.path(configs, fsids)
+		log.Debugf("writing filesystem metrics for fsid: %s", fsid)
+		ch <- prometheus.MustNewConstMetric(fsTotalSpace, prometheus.GaugeValue, float64(fs.statVFS.TotalSpace()), fsid, label)
+		ch <- prometheus.MustNewConstMetric(fsFreeSpace, prometheus.GaugeValue, float64(fs.statVFS.FreeSpace()), fsid, label)
+		for _, path := range fs.paths {
+			ch <- prometheus.MustNewConstMetric(pathFilesystem, prometheus.GaugeValue, 1, path, fsid)
 		}
 	}
 }
 
-func NewSFTPCollector(c client.SFTPClient) prometheus.Collector {
-	return SFTPCollector{sftpClient: c}
+// windowLabel formats the window without its zero units, like 1h rather than
+// 1h0m0s.
+func windowLabel(window time.Duration) string {
+	label := window.String()
+	if strings.HasSuffix(label, "m0s") {
+		label = strings.TrimSuffix(label, "0s")
+	}
+	if strings.HasSuffix(label, "h0m") {
+		label = strings.TrimSuffix(label, "0m")
+	}
+	return label
+}
+
+// callWithContext calls fn in its own goroutine so that a request hanging on
+// the server does not hold the collection once ctx expires.
+// The abandoned goroutine exits when its request fails on closing the SFTP
+// client.
+func callWithContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	var zero T
+	if ctx.Err() != nil {
+		return zero, context.Cause(ctx)
+	}
+	results := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		results <- result{value: value, err: err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-ctx.Done():
+		return zero, context.Cause(ctx)
+	}
+}
+
+func boolToFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func NewSFTPCollector(c client.SFTPClient) *SFTPCollector {
+	return &SFTPCollector{
+		sftpClient:     c,
+		sem:            make(chan struct{}, 1),
+		truncatedPaths: make(map[string]bool),
+		dirCaches:      make(map[string]*dirCache),
+		changes:        make(map[string]*objectChanges),
+		fsids:          make(map[string]uint64),
+	}
 }
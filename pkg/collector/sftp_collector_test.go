@@ -1,12 +1,20 @@
 package collector
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/arunvelsriram/sftp-exporter/pkg/client/walk"
 	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
 	"github.com/arunvelsriram/sftp-exporter/pkg/internal/mocks"
 	"github.com/kr/fs"
@@ -19,6 +27,7 @@ import (
 	"go.uber.org/mock/gomock"
 
 	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
 // Refer: https://github.com/kr/fs/blob/main/filesystem.go
@@ -41,6 +50,267 @@ func (m memKrFs) Join(elem ...string) string {
 	return path.Join(elem...)
 }
 
+// walkFS returns a walker listing some directories ahead, like the walkers of
+// the SFTP client.
+func walkFS(root string, fileSystem fs.FileSystem) *walk.Walker {
+	return walk.New(root, fileSystem, 2)
+}
+
+// collect drains all the metrics written by the collector.
+func (s *SFTPCollectorSuite) collect() []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		s.collector.Collect(ch)
+		close(ch)
+	}()
+
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// filterMetrics returns the metrics with the given name, in the order they were written.
+func filterMetrics(metrics []prometheus.Metric, name string) []*dto.Metric {
+	var filtered []*dto.Metric
+	for _, m := range metrics {
+		if !strings.Contains(m.Desc().String(), fmt.Sprintf("fqName: %q", name)) {
+			continue
+		}
+		metric := &dto.Metric{}
+		_ = m.Write(metric)
+		filtered = append(filtered, metric)
+	}
+	return filtered
+}
+
+// drain discards the remaining metrics until the collection is done.
+func drain(ch chan prometheus.Metric, done chan bool) {
+	for {
+		select {
+		case <-ch:
+		case <-done:
+			return
+		}
+	}
+}
+
+func labels(metric *dto.Metric) map[string]string {
+	l := make(map[string]string)
+	for _, pair := range metric.GetLabel() {
+		l[pair.GetName()] = pair.GetValue()
+	}
+	return l
+}
+
+// recordingKrFs records the number of times each directory is listed.
+type recordingKrFs struct {
+	memKrFs
+	mu   sync.Mutex
+	dirs map[string]int
+}
+
+func (r *recordingKrFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	r.mu.Lock()
+	if r.dirs == nil {
+		r.dirs = make(map[string]int)
+	}
+	r.dirs[dirname]++
+	r.mu.Unlock()
+	return r.memKrFs.ReadDir(dirname)
+}
+
+func (r *recordingKrFs) listed() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.dirs)
+}
+
+// slowKrFs takes the latency to read a directory, like a remote server, and
+// records the peak number of directories read at once.
+type slowKrFs struct {
+	memKrFs
+	latency  time.Duration
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (f *slowKrFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(f.latency)
+	return f.memKrFs.ReadDir(dirname)
+}
+
+// blockingReader blocks reading until unblock is closed, like a file on a hung
+// network filesystem.
+type blockingReader struct {
+	unblock chan struct{}
+}
+
+func (b blockingReader) Read([]byte) (int, error) {
+	<-b.unblock
+	return 0, fmt.Errorf("connection lost")
+}
+
+func (b blockingReader) Close() error {
+	return nil
+}
+
+// blockingKrFs blocks reading the directory until unblock is closed,
+// like a directory on a hung network filesystem.
+type blockingKrFs struct {
+	memKrFs
+	dirname string
+	unblock chan struct{}
+}
+
+func (b blockingKrFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	if dirname == b.dirname {
+		<-b.unblock
+		return nil, fmt.Errorf("connection lost")
+	}
+	return b.memKrFs.ReadDir(dirname)
+}
+
+// linkKrFs adds symbolic links, mapped to their targets, to the file system.
+type linkKrFs struct {
+	memKrFs
+	links map[string]string
+}
+
+type linkInfo struct {
+	name   string
+	target string
+}
+
+func (l linkInfo) Name() string       { return path.Base(l.name) }
+func (l linkInfo) Size() int64        { return int64(len(l.target)) }
+func (l linkInfo) Mode() os.FileMode  { return os.ModeSymlink | 0777 }
+func (l linkInfo) ModTime() time.Time { return time.Time{} }
+func (l linkInfo) IsDir() bool        { return false }
+func (l linkInfo) Sys() any           { return nil }
+
+func (l linkKrFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	infos, err := l.memKrFs.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+	for name, target := range l.links {
+		if path.Dir(name) == dirname {
+			infos = append(infos, linkInfo{name: name, target: target})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (l linkKrFs) Lstat(name string) (os.FileInfo, error) {
+	if target, ok := l.links[name]; ok {
+		return linkInfo{name: name, target: target}, nil
+	}
+	return l.memKrFs.Lstat(name)
+}
+
+// Stat follows the links like the SFTP server does.
+func (l linkKrFs) Stat(name string) (os.FileInfo, error) {
+	for range 10 {
+		target, ok := l.links[name]
+		if !ok {
+			return l.memFs.Stat(name)
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(name), target)
+		}
+		name = target
+	}
+	return nil, fmt.Errorf("too many links")
+}
+
+// RealPath resolves the links of every element of the name like the SFTP
+// server does.
+func (l linkKrFs) RealPath(name string) (string, error) {
+	realPath := "/"
+	elems := strings.Split(name, "/")
+	for hops := 0; len(elems) > 0; elems = elems[1:] {
+		next := path.Join(realPath, elems[0])
+		target, ok := l.links[next]
+		if !ok {
+			realPath = next
+			continue
+		}
+		if hops++; hops > 10 {
+			return "", fmt.Errorf("too many links")
+		}
+		if path.IsAbs(target) {
+			realPath = "/"
+		}
+		elems = append([]string{""}, append(strings.Split(target, "/"), elems[1:]...)...)
+	}
+	return realPath, nil
+}
+
+// ownerKrFs adds the owners the SFTP server reports to the files.
+type ownerKrFs struct {
+	memKrFs
+	owners map[string]*sftp.FileStat
+}
+
+type ownerInfo struct {
+	os.FileInfo
+	stat *sftp.FileStat
+}
+
+func (o ownerInfo) Sys() any { return o.stat }
+
+func (o ownerKrFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	infos, err := o.memKrFs.ReadDir(dirname)
+	for i, info := range infos {
+		if stat, ok := o.owners[path.Join(dirname, info.Name())]; ok {
+			infos[i] = ownerInfo{FileInfo: info, stat: stat}
+		}
+	}
+	return infos, err
+}
+
+func (o ownerKrFs) Lstat(name string) (os.FileInfo, error) {
+	info, err := o.memKrFs.Lstat(name)
+	if stat, ok := o.owners[name]; ok {
+		return ownerInfo{FileInfo: info, stat: stat}, err
+	}
+	return info, err
+}
+
+// listingKrFs records the directories that are listed.
+type listingKrFs struct {
+	memKrFs
+	mu   *sync.Mutex
+	dirs *[]string
+}
+
+func (l listingKrFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	l.mu.Lock()
+	*l.dirs = append(*l.dirs, dirname)
+	l.mu.Unlock()
+	return l.memKrFs.ReadDir(dirname)
+}
+
+func (l listingKrFs) listed() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	dirs := *l.dirs
+	*l.dirs = nil
+	sort.Strings(dirs)
+	return dirs
+}
+
 type SFTPCollectorSuite struct {
 	suite.Suite
 	ctrl       *gomock.Controller
@@ -58,6 +328,20 @@ func (s *SFTPCollectorSuite) SetupTest() {
 	s.sftpClient = mocks.NewMockSFTPClient(s.ctrl)
 	s.collector = NewSFTPCollector(s.sftpClient)
 	viper.Set(viperkeys.SFTPStatVfs, true)
+	viper.Set(viperkeys.SFTPMaxConcurrency, 1)
+	viper.Set(viperkeys.SFTPPathTimeout, 0)
+	viper.Set(viperkeys.ScrapeTimeout, 0)
+	viper.Set(viperkeys.SFTPMaxEntries, 0)
+	viper.Set(viperkeys.SFTPSymlinks, SymlinksCount)
+	viper.Set(viperkeys.SFTPObjectClasses, nil)
+	viper.Set(viperkeys.SFTPObjectPolicy, nil)
+	viper.Set(viperkeys.SFTPMaxOwners, 10)
+	viper.Set(viperkeys.SFTPIncremental, false)
+	viper.Set(viperkeys.SFTPMaxTrackedObjects, 0)
+	viper.Set(viperkeys.SFTPStableAfter, 0)
+	viper.Set(viperkeys.SFTPFiles, nil)
+	viper.Set(viperkeys.SFTPRecentWindows, nil)
+	viper.Set(viperkeys.SFTPMaxFileSize, 1024)
 }
 
 func (s *SFTPCollectorSuite) TearDownTest() {
@@ -76,29 +360,176 @@ func (s *SFTPCollectorSuite) TestSFTPCollectorDescribe() {
 
 	fsTotalSpace := <-ch
 	s.Equal(`Desc{fqName: "sftp_filesystem_total_space_bytes", `+
-		`help: "Total space in the filesystem containing the path", constLabels: {}, variableLabels: {path}}`,
+		`help: "Total space in the filesystem", constLabels: {}, variableLabels: {fsid,path}}`,
 		fsTotalSpace.String(),
 	)
 
 	fsFreeSpace := <-ch
 	s.Equal(`Desc{fqName: "sftp_filesystem_free_space_bytes", `+
-		`help: "Free space in the filesystem containing the path", constLabels: {}, variableLabels: {path}}`,
+		`help: "Free space in the filesystem", constLabels: {}, variableLabels: {fsid,path}}`,
 		fsFreeSpace.String(),
 	)
 
+	pathFilesystem := <-ch
+	s.Equal(`Desc{fqName: "sftp_path_filesystem_info", `+
+		`help: "Maps the path to the filesystem containing it", constLabels: {}, variableLabels: {path,fsid}}`,
+		pathFilesystem.String(),
+	)
+
 	objectCount := <-ch
 	s.Equal(
 		`Desc{fqName: "sftp_objects_available", `+
-			`help: "Number of objects in the path", constLabels: {}, variableLabels: {path}}`,
+			`help: "Number of objects in the path, by class when the path has classes", constLabels: {}, variableLabels: {path,class}}`,
 		objectCount.String(),
 	)
 
 	objectSize := <-ch
 	s.Equal(
 		`Desc{fqName: "sftp_objects_total_size_bytes", `+
-			`help: "Total size of all the objects in the path", constLabels: {}, variableLabels: {path}}`,
+			`help: "Total size of all the objects in the path, by class when the path has classes", constLabels: {}, `+
+			`variableLabels: {path,class}}`,
 		objectSize.String(),
 	)
+
+	objectsInProgress := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_objects_in_progress", `+
+			`help: "Number of objects in the path that are still changing, which are not counted as available", constLabels: {}, variableLabels: {path}}`,
+		objectsInProgress.String(),
+	)
+
+	objectsRecent := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_objects_recent", `+
+			`help: "Number of objects in the path modified within the window", constLabels: {}, variableLabels: {path,window}}`,
+		objectsRecent.String(),
+	)
+
+	objectsTruncated := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_objects_truncated", help: "Tells if walking the path stopped at the maximum number of entries, `+
+			`making the object metrics lower bounds", constLabels: {}, variableLabels: {path}}`,
+		objectsTruncated.String(),
+	)
+
+	ownerObjectCount := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_owner_objects_available", `+
+			`help: "Number of objects in the path by owner uid", constLabels: {}, variableLabels: {path,uid}}`,
+		ownerObjectCount.String(),
+	)
+
+	groupObjectCount := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_group_objects_available", `+
+			`help: "Number of objects in the path by group gid", constLabels: {}, variableLabels: {path,gid}}`,
+		groupObjectCount.String(),
+	)
+
+	policyViolations := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_objects_policy_violations", `+
+			`help: "Number of objects in the path breaking the rule of the expected mode and owner", constLabels: {}, variableLabels: {path,rule}}`,
+		policyViolations.String(),
+	)
+
+	directoryCount := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_directories_available", `+
+			`help: "Number of directories in the path", constLabels: {}, variableLabels: {path}}`,
+		directoryCount.String(),
+	)
+
+	emptyDirectories := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_empty_directories", `+
+			`help: "Number of empty directories in the path", constLabels: {}, variableLabels: {path}}`,
+		emptyDirectories.String(),
+	)
+
+	symlinksBroken := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_symlinks_broken", `+
+			`help: "Number of symbolic links in the path whose target does not exist", constLabels: {}, variableLabels: {path}}`,
+		symlinksBroken.String(),
+	)
+
+	directoryCacheHitRatio := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_directory_cache_hit_ratio", `+
+			`help: "Ratio of the directories of the path whose listing was reused from the previous walk", constLabels: {}, variableLabels: {path}}`,
+		directoryCacheHitRatio.String(),
+	)
+
+	objectsCreated := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_objects_created_total", `+
+			`help: "Number of objects created in the path", constLabels: {}, variableLabels: {path}}`,
+		objectsCreated.String(),
+	)
+
+	objectsRemoved := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_objects_removed_total", `+
+			`help: "Number of objects removed from the path", constLabels: {}, variableLabels: {path}}`,
+		objectsRemoved.String(),
+	)
+
+	objectsModified := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_objects_modified_total", `+
+			`help: "Number of times objects of the path changed in size or modification time", constLabels: {}, variableLabels: {path}}`,
+		objectsModified.String(),
+	)
+
+	bytesAdded := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_bytes_added_total", `+
+			`help: "Number of bytes added to the path by created and growing objects", constLabels: {}, variableLabels: {path}}`,
+		bytesAdded.String(),
+	)
+
+	pathExists := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_path_exists", `+
+			`help: "Tells if the path exists", constLabels: {}, variableLabels: {path}}`,
+		pathExists.String(),
+	)
+
+	pathCollectTimeout := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_path_collect_timeout", `+
+			`help: "Tells if collecting the object metrics of the path timed out", constLabels: {}, variableLabels: {path}}`,
+		pathCollectTimeout.String(),
+	)
+
+	pathPattern := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_path_pattern_info", `+
+			`help: "Maps the path to the configured pattern it was expanded from", constLabels: {}, variableLabels: {pattern,path}}`,
+		pathPattern.String(),
+	)
+
+	fileLines := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_file_lines", `+
+			`help: "Number of lines in the file", constLabels: {}, variableLabels: {path}}`,
+		fileLines.String(),
+	)
+
+	fileChecksum := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_file_checksum_info", `+
+			`help: "SHA-256 checksum of the file", constLabels: {}, variableLabels: {path,sha256}}`,
+		fileChecksum.String(),
+	)
+
+	fileContentMatch := <-ch
+	s.Equal(
+		`Desc{fqName: "sftp_file_content_match", `+
+			`help: "Tells if the first bytes of the file match the pattern", constLabels: {}, variableLabels: {path}}`,
+		fileContentMatch.String(),
+	)
 }
 
 func (s *SFTPCollectorSuite) TestSFTPCollectorDescribeShouldSkipFileSystemMetricsWhenStatVfsIsDisabled() {
@@ -106,7 +537,7 @@ func (s *SFTPCollectorSuite) TestSFTPCollectorDescribeShouldSkipFileSystemMetric
 	ch := make(chan *prometheus.Desc)
 	go s.collector.Describe(ch)
 
-	for range 3 {
+	for range 4 {
 		m := <-ch
 		s.NotContains(m.String(), "filesystem_total_space_bytes")
 		s.NotContains(m.String(), "filesystem_free_space_bytes")
@@ -163,11 +594,11 @@ func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteFSMetrics() {
 	memFs := afero.NewMemMapFs()
 	_ = memFs.MkdirAll("/path0", 0755)
 	_ = memFs.MkdirAll("/path1", 0755)
-	path0Walker := fs.WalkFS("/path0", memKrFs{memFs: memFs})
-	path1Walker := fs.WalkFS("/path1", memKrFs{memFs: memFs})
+	path0Walker := walkFS("/path0", memKrFs{memFs: memFs})
+	path1Walker := walkFS("/path1", memKrFs{memFs: memFs})
 	s.sftpClient.EXPECT().Connect().Return(nil)
-	s.sftpClient.EXPECT().StatVFS("/path0").Return(&sftp.StatVFS{Frsize: 10, Blocks: 1000, Bfree: 100}, nil)
-	s.sftpClient.EXPECT().StatVFS("/path1").Return(&sftp.StatVFS{Frsize: 5, Blocks: 1000, Bfree: 500}, nil)
+	s.sftpClient.EXPECT().StatVFS("/path0").Return(&sftp.StatVFS{Frsize: 10, Blocks: 1000, Bfree: 100, Fsid: 10}, nil)
+	s.sftpClient.EXPECT().StatVFS("/path1").Return(&sftp.StatVFS{Frsize: 5, Blocks: 1000, Bfree: 500, Fsid: 11}, nil)
 	s.sftpClient.EXPECT().Walk("/path0").Return(path0Walker)
 	s.sftpClient.EXPECT().Walk("/path1").Return(path1Walker)
 	s.sftpClient.EXPECT().Close()
@@ -186,75 +617,162 @@ func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteFSMetrics() {
 	totalSpace1 := <-ch
 	desc = totalSpace1.Desc()
 	_ = totalSpace1.Write(metric)
-	s.Equal(`Desc{fqName: "sftp_filesystem_total_space_bytes", help: "Total space in the filesystem containing the path", `+
-		`constLabels: {}, variableLabels: {path}}`, desc.String())
+	s.Equal(`Desc{fqName: "sftp_filesystem_total_space_bytes", help: "Total space in the filesystem", `+
+		`constLabels: {}, variableLabels: {fsid,path}}`, desc.String())
 	s.Equal(10000.0, metric.GetGauge().GetValue())
-	s.Equal("path", metric.GetLabel()[0].GetName())
-	s.Equal("/path0", metric.GetLabel()[0].GetValue())
+	s.Equal(map[string]string{"fsid": "a", "path": "/path0"}, labels(metric))
 
 	freeSpace1 := <-ch
 	desc = freeSpace1.Desc()
 	_ = freeSpace1.Write(metric)
-	s.Equal(`Desc{fqName: "sftp_filesystem_free_space_bytes", help: "Free space in the filesystem containing the path", `+
-		`constLabels: {}, variableLabels: {path}}`, desc.String())
+	s.Equal(`Desc{fqName: "sftp_filesystem_free_space_bytes", help: "Free space in the filesystem", `+
+		`constLabels: {}, variableLabels: {fsid,path}}`, desc.String())
 	s.Equal(1000.0, metric.GetGauge().GetValue())
-	s.Equal("path", metric.GetLabel()[0].GetName())
-	s.Equal("/path0", metric.GetLabel()[0].GetValue())
+	s.Equal(map[string]string{"fsid": "a", "path": "/path0"}, labels(metric))
+
+	pathFilesystem1 := <-ch
+	desc = pathFilesystem1.Desc()
+	_ = pathFilesystem1.Write(metric)
+	s.Equal(`Desc{fqName: "sftp_path_filesystem_info", help: "Maps the path to the filesystem containing it", `+
+		`constLabels: {}, variableLabels: {path,fsid}}`, desc.String())
+	s.Equal(1.0, metric.GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path0", "fsid": "a"}, labels(metric))
 
 	totalSpace2 := <-ch
-	desc = totalSpace2.Desc()
 	_ = totalSpace2.Write(metric)
-	s.Equal(`Desc{fqName: "sftp_filesystem_total_space_bytes", help: "Total space in the filesystem containing the path", `+
-		`constLabels: {}, variableLabels: {path}}`, desc.String())
 	s.Equal(5000.0, metric.GetGauge().GetValue())
-	s.Equal("path", metric.GetLabel()[0].GetName())
-	s.Equal("/path1", metric.GetLabel()[0].GetValue())
+	s.Equal(map[string]string{"fsid": "b", "path": "/path1"}, labels(metric))
 
 	freeSpace2 := <-ch
-	desc = freeSpace2.Desc()
 	_ = freeSpace2.Write(metric)
-	s.Equal(`Desc{fqName: "sftp_filesystem_free_space_bytes", help: "Free space in the filesystem containing the path", `+
-		`constLabels: {}, variableLabels: {path}}`, desc.String())
 	s.Equal(2500.0, metric.GetGauge().GetValue())
-	s.Equal("path", metric.GetLabel()[0].GetName())
-	s.Equal("/path1", metric.GetLabel()[0].GetValue())
+	s.Equal(map[string]string{"fsid": "b", "path": "/path1"}, labels(metric))
 
-	<-ch
-	<-ch
-	<-ch
-	<-ch
-	<-done
+	pathFilesystem2 := <-ch
+	_ = pathFilesystem2.Write(metric)
+	s.Equal(map[string]string{"path": "/path1", "fsid": "b"}, labels(metric))
+
+	drain(ch, done)
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteFSMetricsOncePerFilesystem() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/upload/path0", "/upload/path1/a"})
+	memFs := afero.NewMemMapFs()
+	_ = memFs.MkdirAll("/upload/path0", 0755)
+	_ = memFs.MkdirAll("/upload/path1/a", 0755)
+	path0Walker := walkFS("/upload/path0", memKrFs{memFs: memFs})
+	path1Walker := walkFS("/upload/path1/a", memKrFs{memFs: memFs})
+	statVFS := &sftp.StatVFS{Frsize: 10, Blocks: 1000, Bfree: 100, Fsid: 42}
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().StatVFS("/upload/path0").Return(statVFS, nil)
+	s.sftpClient.EXPECT().StatVFS("/upload/path1/a").Return(statVFS, nil)
+	s.sftpClient.EXPECT().Walk("/upload/path0").Return(path0Walker)
+	s.sftpClient.EXPECT().Walk("/upload/path1/a").Return(path1Walker)
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	totalSpace := filterMetrics(metrics, "sftp_filesystem_total_space_bytes")
+	s.Len(totalSpace, 1)
+	s.Equal(10000.0, totalSpace[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"fsid": "2a", "path": "/upload/path0"}, labels(totalSpace[0]))
+
+	freeSpace := filterMetrics(metrics, "sftp_filesystem_free_space_bytes")
+	s.Len(freeSpace, 1)
+	s.Equal(1000.0, freeSpace[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"fsid": "2a", "path": "/upload/path0"}, labels(freeSpace[0]))
+
+	pathFilesystem := filterMetrics(metrics, "sftp_path_filesystem_info")
+	s.Len(pathFilesystem, 2)
+	s.Equal(map[string]string{"path": "/upload/path0", "fsid": "2a"}, labels(pathFilesystem[0]))
+	s.Equal(map[string]string{"path": "/upload/path1/a", "fsid": "2a"}, labels(pathFilesystem[1]))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldKeepFSLabelWhilePathFails() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/upload/path0", "/upload/path1/a"})
+	memFs := afero.NewMemMapFs()
+	_ = memFs.MkdirAll("/upload/path0", 0755)
+	_ = memFs.MkdirAll("/upload/path1/a", 0755)
+	statVFS := &sftp.StatVFS{Frsize: 10, Blocks: 1000, Bfree: 100, Fsid: 42}
+	s.sftpClient.EXPECT().Connect().Return(nil).Times(2)
+	gomock.InOrder(
+		s.sftpClient.EXPECT().StatVFS("/upload/path0").Return(statVFS, nil),
+		s.sftpClient.EXPECT().StatVFS("/upload/path0").Return(nil, fmt.Errorf("permission denied")),
+	)
+	s.sftpClient.EXPECT().StatVFS("/upload/path1/a").Return(statVFS, nil).Times(2)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(4)
+	s.sftpClient.EXPECT().Close().Times(2)
+
+	s.collect()
+	metrics := s.collect()
+
+	totalSpace := filterMetrics(metrics, "sftp_filesystem_total_space_bytes")
+	s.Len(totalSpace, 1)
+	s.Equal(map[string]string{"fsid": "2a", "path": "/upload/path0"}, labels(totalSpace[0]))
+	pathFilesystem := filterMetrics(metrics, "sftp_path_filesystem_info")
+	s.Len(pathFilesystem, 1)
+	s.Equal(map[string]string{"path": "/upload/path1/a", "fsid": "2a"}, labels(pathFilesystem[0]))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteFSMetricsPerPathWithoutFsid() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0", "/path1"})
+	memFs := afero.NewMemMapFs()
+	_ = memFs.MkdirAll("/path0", 0755)
+	_ = memFs.MkdirAll("/path1", 0755)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().StatVFS("/path0").Return(&sftp.StatVFS{Frsize: 10, Blocks: 1000, Bfree: 100}, nil)
+	s.sftpClient.EXPECT().StatVFS("/path1").Return(&sftp.StatVFS{Frsize: 5, Blocks: 1000, Bfree: 500}, nil)
+	s.sftpClient.EXPECT().Walk("/path0").Return(walkFS("/path0", memKrFs{memFs: memFs}))
+	s.sftpClient.EXPECT().Walk("/path1").Return(walkFS("/path1", memKrFs{memFs: memFs}))
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	totalSpace := filterMetrics(metrics, "sftp_filesystem_total_space_bytes")
+	s.Len(totalSpace, 2)
+	s.Equal(map[string]string{"fsid": "0", "path": "/path0"}, labels(totalSpace[0]))
+	s.Equal(10000.0, totalSpace[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"fsid": "0", "path": "/path1"}, labels(totalSpace[1]))
+	s.Equal(5000.0, totalSpace[1].GetGauge().GetValue())
 }
 
 func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotWriteFSMetricsOnError() {
 	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
 	memFs := afero.NewMemMapFs()
 	_ = memFs.MkdirAll("/path0", 0755)
-	path0Walker := fs.WalkFS("/path0", memKrFs{memFs: memFs})
+	path0Walker := walkFS("/path0", memKrFs{memFs: memFs})
 	s.sftpClient.EXPECT().Connect().Return(nil)
 	s.sftpClient.EXPECT().StatVFS("/path0").Return(nil, fmt.Errorf("failed to get VFS stats"))
 	s.sftpClient.EXPECT().Walk("/path0").Return(path0Walker)
 	s.sftpClient.EXPECT().Close()
-	ch := make(chan prometheus.Metric)
-	done := make(chan bool)
+	for _, m := range s.collect() {
+		s.NotContains(m.Desc().String(), "filesystem_total_space_bytes")
+		s.NotContains(m.Desc().String(), "filesystem_free_space_bytes")
+	}
+}
 
-	go func() {
-		s.collector.Collect(ch)
-		done <- true
-	}()
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldGiveUpOnHangingStatVFS() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+	viper.Set(viperkeys.ScrapeTimeout, 50*time.Millisecond)
+	unblock := make(chan struct{})
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().StatVFS("/path0").DoAndReturn(func(string) (*sftp.StatVFS, error) {
+		<-unblock
+		return nil, fmt.Errorf("connection lost")
+	})
+	s.sftpClient.EXPECT().Close().DoAndReturn(func() error {
+		close(unblock)
+		return nil
+	})
 
-	m1 := <-ch
-	s.NotContains(m1.Desc().String(), "filesystem_total_space_bytes")
-	s.NotContains(m1.Desc().String(), "filesystem_free_space_bytes")
-	m2 := <-ch
-	s.NotContains(m2.Desc().String(), "filesystem_total_space_bytes")
-	s.NotContains(m2.Desc().String(), "filesystem_free_space_bytes")
-	m3 := <-ch
-	s.NotContains(m3.Desc().String(), "filesystem_total_space_bytes")
-	s.NotContains(m3.Desc().String(), "filesystem_free_space_bytes")
-	close(ch)
+	metrics := s.collect()
 
-	<-done
+	s.Empty(filterMetrics(metrics, "sftp_filesystem_total_space_bytes"))
+	pathCollectTimeout := filterMetrics(metrics, "sftp_path_collect_timeout")
+	s.Len(pathCollectTimeout, 1)
+	s.Equal(1.0, pathCollectTimeout[0].GetGauge().GetValue())
 }
 
 func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteObjectMetrics() {
@@ -266,67 +784,84 @@ func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteObjectMetrics()
 	_ = afero.WriteFile(memFs, "/path0/1/a/1a.txt", []byte("1a"), 0644)
 	_ = memFs.MkdirAll("/path1/empty-dir", 0755)
 	_ = afero.WriteFile(memFs, "/path1/1.txt", []byte("helloworld"), 0644)
-	path0Walker := fs.WalkFS("/path0", memKrFs{memFs: memFs})
-	path1Walker := fs.WalkFS("/path1", memKrFs{memFs: memFs})
+	path0Walker := walkFS("/path0", memKrFs{memFs: memFs})
+	path1Walker := walkFS("/path1", memKrFs{memFs: memFs})
 	s.sftpClient.EXPECT().Connect().Return(nil)
 	s.sftpClient.EXPECT().StatVFS("/path0").Return(&sftp.StatVFS{}, nil)
 	s.sftpClient.EXPECT().StatVFS("/path1").Return(&sftp.StatVFS{}, nil)
 	s.sftpClient.EXPECT().Walk("/path0").Return(path0Walker)
 	s.sftpClient.EXPECT().Walk("/path1").Return(path1Walker)
 	s.sftpClient.EXPECT().Close()
-	ch := make(chan prometheus.Metric)
-	done := make(chan bool)
 
-	go func() {
-		s.collector.Collect(ch)
-		done <- true
-	}()
+	metrics := s.collect()
 
-	<-ch
-	<-ch
-	<-ch
-	<-ch
-	<-ch
-	metric := &dto.Metric{}
-	var desc *prometheus.Desc
+	objectCount := filterMetrics(metrics, "sftp_objects_available")
+	s.Len(objectCount, 2)
+	s.Equal(3.0, objectCount[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path0", "class": ""}, labels(objectCount[0]))
+	s.Equal(1.0, objectCount[1].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path1", "class": ""}, labels(objectCount[1]))
 
-	objectCount1 := <-ch
-	desc = objectCount1.Desc()
-	_ = objectCount1.Write(metric)
-	s.Equal(`Desc{fqName: "sftp_objects_available", help: "Number of objects in the path", `+
-		`constLabels: {}, variableLabels: {path}}`, desc.String())
-	s.Equal(3.0, metric.GetGauge().GetValue())
-	s.Equal("path", metric.GetLabel()[0].GetName())
-	s.Equal("/path0", metric.GetLabel()[0].GetValue())
-
-	objectSize1 := <-ch
-	desc = objectSize1.Desc()
-	_ = objectSize1.Write(metric)
-	s.Equal(`Desc{fqName: "sftp_objects_total_size_bytes", help: "Total size of all the objects in the path", `+
-		`constLabels: {}, variableLabels: {path}}`, desc.String())
-	s.Equal(4.0, metric.GetGauge().GetValue())
-	s.Equal("path", metric.GetLabel()[0].GetName())
-	s.Equal("/path0", metric.GetLabel()[0].GetValue())
-
-	objectCount2 := <-ch
-	desc = objectCount2.Desc()
-	_ = objectCount2.Write(metric)
-	s.Equal(`Desc{fqName: "sftp_objects_available", help: "Number of objects in the path", `+
-		`constLabels: {}, variableLabels: {path}}`, desc.String())
-	s.Equal(1.0, metric.GetGauge().GetValue())
-	s.Equal("path", metric.GetLabel()[0].GetName())
-	s.Equal("/path1", metric.GetLabel()[0].GetValue())
-
-	objectSize2 := <-ch
-	desc = objectSize2.Desc()
-	_ = objectSize2.Write(metric)
-	s.Equal(`Desc{fqName: "sftp_objects_total_size_bytes", help: "Total size of all the objects in the path", `+
-		`constLabels: {}, variableLabels: {path}}`, desc.String())
-	s.Equal(10.0, metric.GetGauge().GetValue())
-	s.Equal("path", metric.GetLabel()[0].GetName())
-	s.Equal("/path1", metric.GetLabel()[0].GetValue())
+	objectSize := filterMetrics(metrics, "sftp_objects_total_size_bytes")
+	s.Len(objectSize, 2)
+	s.Equal(4.0, objectSize[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path0", "class": ""}, labels(objectSize[0]))
+	s.Equal(10.0, objectSize[1].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path1", "class": ""}, labels(objectSize[1]))
 
-	<-done
+	objectsTruncated := filterMetrics(metrics, "sftp_objects_truncated")
+	s.Len(objectsTruncated, 2)
+	s.Equal(0.0, objectsTruncated[0].GetGauge().GetValue())
+	s.Equal(0.0, objectsTruncated[1].GetGauge().GetValue())
+
+	directoryCount := filterMetrics(metrics, "sftp_directories_available")
+	s.Len(directoryCount, 2)
+	s.Equal(2.0, directoryCount[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path0"}, labels(directoryCount[0]))
+	s.Equal(1.0, directoryCount[1].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path1"}, labels(directoryCount[1]))
+
+	emptyDirectories := filterMetrics(metrics, "sftp_empty_directories")
+	s.Len(emptyDirectories, 2)
+	s.Equal(0.0, emptyDirectories[0].GetGauge().GetValue())
+	s.Equal(1.0, emptyDirectories[1].GetGauge().GetValue())
+
+	pathExists := filterMetrics(metrics, "sftp_path_exists")
+	s.Len(pathExists, 2)
+	s.Equal(1.0, pathExists[0].GetGauge().GetValue())
+	s.Equal(1.0, pathExists[1].GetGauge().GetValue())
+
+	pathCollectTimeout := filterMetrics(metrics, "sftp_path_collect_timeout")
+	s.Len(pathCollectTimeout, 2)
+	s.Equal(0.0, pathCollectTimeout[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path0"}, labels(pathCollectTimeout[0]))
+	s.Equal(0.0, pathCollectTimeout[1].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path1"}, labels(pathCollectTimeout[1]))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWritePathExistsMetric() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0", "/missing"})
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	memFs := afero.NewMemMapFs()
+	_ = memFs.MkdirAll("/path0", 0755)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk("/path0").Return(walkFS("/path0", memKrFs{memFs: memFs}))
+	s.sftpClient.EXPECT().Walk("/missing").Return(walkFS("/missing", memKrFs{memFs: memFs}))
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	pathExists := filterMetrics(metrics, "sftp_path_exists")
+	s.Len(pathExists, 2)
+	s.Equal(map[string]string{"path": "/path0"}, labels(pathExists[0]))
+	s.Equal(1.0, pathExists[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/missing"}, labels(pathExists[1]))
+	s.Equal(0.0, pathExists[1].GetGauge().GetValue())
+
+	objectCount := filterMetrics(metrics, "sftp_objects_available")
+	s.Len(objectCount, 1)
+	s.Equal(map[string]string{"path": "/path0", "class": ""}, labels(objectCount[0]))
+	s.Equal(0.0, objectCount[0].GetGauge().GetValue())
 }
 
 func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotWriteObjectMetricsOnError() {
@@ -334,31 +869,16 @@ func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotWriteObjectMetrics
 	memFs := afero.NewMemMapFs()
 	_ = memFs.MkdirAll("/errorpath", 0755)
 	_ = afero.WriteFile(memFs, "/errorpath/file.txt", []byte("helloworld"), 0000)
-	walker := fs.WalkFS("/errorpath", memKrFs{memFs: memFs})
+	walker := walkFS("/errorpath", memKrFs{memFs: memFs})
 	s.sftpClient.EXPECT().Connect().Return(nil)
 	s.sftpClient.EXPECT().StatVFS("/errorpath").Return(&sftp.StatVFS{}, nil)
 	s.sftpClient.EXPECT().Walk("/errorpath").Return(walker)
 	s.sftpClient.EXPECT().Close()
-	ch := make(chan prometheus.Metric)
-	done := make(chan bool)
 
-	go func() {
-		s.collector.Collect(ch)
-		done <- true
-	}()
-
-	m1 := <-ch
-	s.NotContains(m1.Desc().String(), "objects_available")
-	s.NotContains(m1.Desc().String(), "objects_total_size_bytes")
-	m2 := <-ch
-	s.NotContains(m2.Desc().String(), "objects_available")
-	s.NotContains(m2.Desc().String(), "objects_total_size_bytes")
-	m3 := <-ch
-	s.NotContains(m3.Desc().String(), "objects_available")
-	s.NotContains(m3.Desc().String(), "objects_total_size_bytes")
-	close(ch)
-
-	<-done
+	for _, m := range s.collect() {
+		s.NotContains(m.Desc().String(), "objects_available")
+		s.NotContains(m.Desc().String(), "objects_total_size_bytes")
+	}
 }
 
 func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotCallStatVFS() {
@@ -366,23 +886,890 @@ func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotCallStatVFS() {
 	viper.Set(viperkeys.SFTPStatVfs, false)
 	memFs := afero.NewMemMapFs()
 	_ = memFs.MkdirAll("/path0", 0755)
-	path0Walker := fs.WalkFS("/path0", memKrFs{memFs: memFs})
+	path0Walker := walkFS("/path0", memKrFs{memFs: memFs})
 	s.sftpClient.EXPECT().Connect().Return(nil)
 	s.sftpClient.EXPECT().Walk("/path0").Return(path0Walker)
 	s.sftpClient.EXPECT().Close()
-	ch := make(chan prometheus.Metric)
-	done := make(chan bool)
-
-	go func() {
-		s.collector.Collect(ch)
-		done <- true
-	}()
-
-	for range 3 {
-		m := <-ch
+	for _, m := range s.collect() {
 		s.NotContains(m.Desc().String(), "filesystem_total_space_bytes")
 		s.NotContains(m.Desc().String(), "filesystem_free_space_bytes")
 	}
-	close(ch)
-	<-done
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteObjectMetricsWhenWalkingConcurrently() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0", "/path1"})
+	viper.Set(viperkeys.SFTPMaxConcurrency, 4)
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	memFs := afero.NewMemMapFs()
+	for i := range 10 {
+		dir := fmt.Sprintf("/path0/%d", i)
+		_ = memFs.MkdirAll(path.Join(dir, "nested"), 0755)
+		_ = afero.WriteFile(memFs, path.Join(dir, "file.txt"), []byte("file"), 0644)
+		_ = afero.WriteFile(memFs, path.Join(dir, "nested", "file.txt"), []byte("nested"), 0644)
+	}
+	_ = memFs.MkdirAll("/path0/5/nested/empty", 0755)
+	_ = memFs.MkdirAll("/path1", 0755)
+	_ = afero.WriteFile(memFs, "/path1/1.txt", []byte("helloworld"), 0644)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).MinTimes(2)
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	objectCount := filterMetrics(metrics, "sftp_objects_available")
+	s.Len(objectCount, 2)
+	s.Equal(20.0, objectCount[0].GetGauge().GetValue())
+	s.Equal("/path0", labels(objectCount[0])["path"])
+	s.Equal(1.0, objectCount[1].GetGauge().GetValue())
+	s.Equal("/path1", labels(objectCount[1])["path"])
+
+	objectSize := filterMetrics(metrics, "sftp_objects_total_size_bytes")
+	s.Len(objectSize, 2)
+	s.Equal(100.0, objectSize[0].GetGauge().GetValue())
+	s.Equal(10.0, objectSize[1].GetGauge().GetValue())
+
+	directoryCount := filterMetrics(metrics, "sftp_directories_available")
+	s.Len(directoryCount, 2)
+	s.Equal(21.0, directoryCount[0].GetGauge().GetValue())
+	s.Equal(0.0, directoryCount[1].GetGauge().GetValue())
+
+	emptyDirectories := filterMetrics(metrics, "sftp_empty_directories")
+	s.Len(emptyDirectories, 2)
+	s.Equal(1.0, emptyDirectories[0].GetGauge().GetValue())
+	s.Equal(0.0, emptyDirectories[1].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotListMoreDirectoriesAtOnceThanMaxConcurrency() {
+	s.assertPeakListings(0, 3)
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldListPrefetchedDirectoriesOnTopOfMaxConcurrency() {
+	s.assertPeakListings(2, 3*(1+2))
+}
+
+// assertPeakListings walks two paths with three workers, each walk listing
+// prefetch directories ahead, and checks that no more than maxListings
+// directories are listed at once.
+func (s *SFTPCollectorSuite) assertPeakListings(prefetch int, maxListings int32) {
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0", "/path1"})
+	viper.Set(viperkeys.SFTPMaxConcurrency, 3)
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	memFs := afero.NewMemMapFs()
+	for _, root := range []string{"/path0", "/path1"} {
+		for i := range 10 {
+			_ = afero.WriteFile(memFs, fmt.Sprintf("%s/%d/nested/file.txt", root, i), []byte("file"), 0644)
+		}
+	}
+	fileSystem := &slowKrFs{memKrFs: memKrFs{memFs: memFs}, latency: 5 * time.Millisecond}
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walk.New(root, fileSystem, prefetch)
+	}).Times(2)
+	s.sftpClient.EXPECT().Close()
+
+	objectCount := filterMetrics(s.collect(), "sftp_objects_available")
+
+	s.Len(objectCount, 2)
+	s.Equal(10.0, objectCount[0].GetGauge().GetValue())
+	s.Equal(10.0, objectCount[1].GetGauge().GetValue())
+	s.LessOrEqual(fileSystem.peak.Load(), maxListings)
+	s.Greater(fileSystem.peak.Load(), int32(1))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldListEachDirectoryOnceWhenWalkingConcurrently() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/root"})
+	viper.Set(viperkeys.SFTPMaxConcurrency, 4)
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	memFs := afero.NewMemMapFs()
+	for _, dir := range []string{"a", "b", "c", "d", "e", "f"} {
+		_ = memFs.MkdirAll(path.Join("/root", dir, "nested"), 0755)
+		_ = afero.WriteFile(memFs, path.Join("/root", dir, "nested", "file.txt"), []byte("file"), 0644)
+	}
+	fileSystem := &recordingKrFs{memKrFs: memKrFs{memFs: memFs}}
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk("/root").Return(walkFS("/root", fileSystem))
+	s.sftpClient.EXPECT().Close()
+
+	objectCount := filterMetrics(s.collect(), "sftp_objects_available")
+
+	s.Len(objectCount, 1)
+	s.Equal(6.0, objectCount[0].GetGauge().GetValue())
+	listed := fileSystem.listed()
+	s.Len(listed, 1+6*2)
+	for dir, n := range listed {
+		s.Equal(1, n, dir)
+	}
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWritePathTimeoutMetric() {
+	viper.Set(viperkeys.SFTPPaths, []any{"/path0", map[string]any{"path": "/hung", "timeout": "50ms"}})
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	memFs := afero.NewMemMapFs()
+	_ = memFs.MkdirAll("/hung", 0755)
+	_ = memFs.MkdirAll("/path0", 0755)
+	_ = afero.WriteFile(memFs, "/path0/1.txt", []byte("helloworld"), 0644)
+	unblock := make(chan struct{})
+	hungFs := blockingKrFs{memKrFs: memKrFs{memFs: memFs}, dirname: "/hung", unblock: unblock}
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk("/path0").Return(walkFS("/path0", memKrFs{memFs: memFs}))
+	s.sftpClient.EXPECT().Walk("/hung").Return(walkFS("/hung", hungFs))
+	s.sftpClient.EXPECT().Close().DoAndReturn(func() error {
+		close(unblock)
+		return nil
+	})
+
+	metrics := s.collect()
+
+	objectCount := filterMetrics(metrics, "sftp_objects_available")
+	s.Len(objectCount, 1)
+	s.Equal("/path0", labels(objectCount[0])["path"])
+	s.Equal(1.0, objectCount[0].GetGauge().GetValue())
+
+	pathCollectTimeout := filterMetrics(metrics, "sftp_path_collect_timeout")
+	s.Len(pathCollectTimeout, 2)
+	s.Equal("/path0", labels(pathCollectTimeout[0])["path"])
+	s.Equal(0.0, pathCollectTimeout[0].GetGauge().GetValue())
+	s.Equal("/hung", labels(pathCollectTimeout[1])["path"])
+	s.Equal(1.0, pathCollectTimeout[1].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectWithContextShouldStopWhenContextExpires() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/hung", "/path0"})
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	memFs := afero.NewMemMapFs()
+	_ = memFs.MkdirAll("/hung", 0755)
+	_ = memFs.MkdirAll("/path0", 0755)
+	unblock := make(chan struct{})
+	hungFs := blockingKrFs{memKrFs: memKrFs{memFs: memFs}, dirname: "/hung", unblock: unblock}
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk("/hung").Return(walkFS("/hung", hungFs))
+	s.sftpClient.EXPECT().Close().DoAndReturn(func() error {
+		close(unblock)
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.collector = s.collector.(*SFTPCollector).WithContext(ctx)
+
+	metrics := s.collect()
+
+	s.Empty(filterMetrics(metrics, "sftp_objects_available"))
+	pathCollectTimeout := filterMetrics(metrics, "sftp_path_collect_timeout")
+	s.Len(pathCollectTimeout, 2)
+	s.Equal(1.0, pathCollectTimeout[0].GetGauge().GetValue())
+	s.Equal(1.0, pathCollectTimeout[1].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldTruncateWalkAtMaxEntries() {
+	viper.Set(viperkeys.SFTPPaths, []any{map[string]any{"path": "/path0", "max-entries": 5}})
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	memFs := afero.NewMemMapFs()
+	for i := range 10 {
+		_ = afero.WriteFile(memFs, fmt.Sprintf("/path0/%d.txt", i), []byte("file"), 0644)
+	}
+	s.sftpClient.EXPECT().Connect().Return(nil).Times(2)
+	s.sftpClient.EXPECT().Walk("/path0").DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(2)
+	s.sftpClient.EXPECT().Close().Times(2)
+	logs := logtest.NewGlobal()
+	defer logs.Reset()
+
+	for range 2 {
+		metrics := s.collect()
+
+		objectCount := filterMetrics(metrics, "sftp_objects_available")
+		s.Len(objectCount, 1)
+		s.Equal(4.0, objectCount[0].GetGauge().GetValue())
+		objectsTruncated := filterMetrics(metrics, "sftp_objects_truncated")
+		s.Len(objectsTruncated, 1)
+		s.Equal(1.0, objectsTruncated[0].GetGauge().GetValue())
+	}
+
+	warnings := 0
+	for _, entry := range logs.AllEntries() {
+		if entry.Level == log.WarnLevel {
+			warnings++
+		}
+	}
+	s.Equal(1, warnings)
+}
+
+func (s *SFTPCollectorSuite) expectSymlinkFs() {
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/path0/file.txt", []byte("file"), 0644)
+	_ = afero.WriteFile(memFs, "/data/big.txt", []byte("big file"), 0644)
+	_ = afero.WriteFile(memFs, "/data/nested/file.txt", []byte("nested"), 0644)
+	linkFs := linkKrFs{
+		memKrFs: memKrFs{memFs: memFs},
+		links: map[string]string{
+			"/path0/broken":   "/nowhere",
+			"/path0/data":     "/data",
+			"/path0/data2":    "../data/nested",
+			"/path0/big":      "/data/big.txt",
+			"/path0/loop":     "/path0",
+			"/data/nested/up": "..",
+			// a relative link back to the path through a linked parent
+			"/alias":            "path0",
+			"/data/nested/back": "../../alias/./",
+		},
+	}
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, linkFs)
+	}).AnyTimes()
+	s.sftpClient.EXPECT().Stat(gomock.Any()).DoAndReturn(linkFs.Stat).AnyTimes()
+	s.sftpClient.EXPECT().RealPath(gomock.Any()).DoAndReturn(linkFs.RealPath).AnyTimes()
+	s.sftpClient.EXPECT().Close()
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldApplySymlinksPolicy() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	cases := []struct {
+		policy         string
+		objectCount    float64
+		objectSize     float64
+		directoryCount float64
+		symlinksBroken []float64
+	}{
+		// the links are skipped
+		{policy: SymlinksSkip, objectCount: 1, objectSize: 4},
+		// the links are objects of the size of their target path
+		{policy: SymlinksCount, objectCount: 6, objectSize: 4 + 13 + 8 + 5 + 14 + 6, symlinksBroken: []float64{1}},
+		// /data and the /data/nested directory it contains are walked once,
+		// the links back to /data and /path0, relative or through /alias,
+		// are not followed
+		{policy: SymlinksFollow, objectCount: 4, objectSize: 4 + 8 + 8 + 6, directoryCount: 2, symlinksBroken: []float64{1}},
+	}
+	for _, tc := range cases {
+		s.Run(tc.policy, func() {
+			viper.Set(viperkeys.SFTPPaths, []any{map[string]any{"path": "/path0", "symlinks": tc.policy}})
+			s.expectSymlinkFs()
+
+			metrics := s.collect()
+
+			objectCount := filterMetrics(metrics, "sftp_objects_available")
+			s.Len(objectCount, 1)
+			s.Equal(tc.objectCount, objectCount[0].GetGauge().GetValue())
+			objectSize := filterMetrics(metrics, "sftp_objects_total_size_bytes")
+			s.Len(objectSize, 1)
+			s.Equal(tc.objectSize, objectSize[0].GetGauge().GetValue())
+			directoryCount := filterMetrics(metrics, "sftp_directories_available")
+			s.Len(directoryCount, 1)
+			s.Equal(tc.directoryCount, directoryCount[0].GetGauge().GetValue())
+			var symlinksBroken []float64
+			for _, m := range filterMetrics(metrics, "sftp_symlinks_broken") {
+				symlinksBroken = append(symlinksBroken, m.GetGauge().GetValue())
+			}
+			s.Equal(tc.symlinksBroken, symlinksBroken)
+		})
+	}
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteClassMetrics() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPObjectClasses, []any{
+		map[string]any{"name": "invoices", "pattern": "*.xml"},
+		map[string]any{"name": "archives", "pattern": "*.zip|*.gz"},
+	})
+	viper.Set(viperkeys.SFTPPaths, []any{
+		"/path0",
+		map[string]any{"path": "/path1", "classes": []any{map[string]any{"name": "text", "pattern": "*.txt"}}},
+	})
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/path0/a.xml", []byte("invoice"), 0644)
+	_ = afero.WriteFile(memFs, "/path0/dir/b.xml", []byte("invoice"), 0644)
+	_ = afero.WriteFile(memFs, "/path0/c.gz", []byte("gz"), 0644)
+	_ = afero.WriteFile(memFs, "/path0/d.txt", []byte("text"), 0644)
+	_ = afero.WriteFile(memFs, "/path1/a.xml", []byte("invoice"), 0644)
+	_ = afero.WriteFile(memFs, "/path1/b.txt", []byte("text"), 0644)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(2)
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	counts := map[string]float64{}
+	for _, m := range filterMetrics(metrics, "sftp_objects_available") {
+		counts[labels(m)["path"]+" "+labels(m)["class"]] = m.GetGauge().GetValue()
+	}
+	s.Equal(map[string]float64{
+		"/path0 invoices":     2,
+		"/path0 archives":     1,
+		"/path0 unclassified": 1,
+		"/path1 text":         1,
+		"/path1 unclassified": 1,
+	}, counts)
+	sizes := map[string]float64{}
+	for _, m := range filterMetrics(metrics, "sftp_objects_total_size_bytes") {
+		sizes[labels(m)["path"]+" "+labels(m)["class"]] = m.GetGauge().GetValue()
+	}
+	s.Equal(14.0, sizes["/path0 invoices"])
+	s.Equal(4.0, sizes["/path1 text"])
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotSplitObjectMetricsWithoutClasses() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/path0/a.xml", []byte("invoice"), 0644)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk("/path0").Return(walkFS("/path0", memKrFs{memFs: memFs}))
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	objectCount := filterMetrics(metrics, "sftp_objects_available")
+	s.Len(objectCount, 1)
+	s.Equal(map[string]string{"path": "/path0", "class": ""}, labels(objectCount[0]))
+	s.Equal(1.0, objectCount[0].GetGauge().GetValue())
+	objectSize := filterMetrics(metrics, "sftp_objects_total_size_bytes")
+	s.Len(objectSize, 1)
+	s.Equal(map[string]string{"path": "/path0", "class": ""}, labels(objectSize[0]))
+	s.Equal(7.0, objectSize[0].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteOwnerMetrics() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPMaxOwners, 2)
+	viper.Set(viperkeys.SFTPObjectPolicy, map[string]any{"mode": "0640", "uid": 1000})
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+	memFs := afero.NewMemMapFs()
+	ownerFs := ownerKrFs{memKrFs: memKrFs{memFs: memFs}, owners: map[string]*sftp.FileStat{}}
+	files := []struct {
+		name     string
+		mode     os.FileMode
+		uid, gid uint32
+	}{
+		{name: "/path0/a.txt", mode: 0644, uid: 1000, gid: 100},
+		{name: "/path0/b.txt", mode: 0640, uid: 1000, gid: 100},
+		{name: "/path0/c.txt", mode: 0600, uid: 1001, gid: 100},
+		{name: "/path0/d.txt", mode: 0600, uid: 1002, gid: 101},
+		{name: "/path0/e.txt", mode: 0644, uid: 1003, gid: 101},
+	}
+	for _, file := range files {
+		_ = afero.WriteFile(memFs, file.name, []byte("file"), file.mode)
+		ownerFs.owners[file.name] = &sftp.FileStat{UID: file.uid, GID: file.gid}
+	}
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk("/path0").Return(walkFS("/path0", ownerFs))
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	uids := map[string]float64{}
+	for _, m := range filterMetrics(metrics, "sftp_owner_objects_available") {
+		uids[labels(m)["uid"]] = m.GetGauge().GetValue()
+	}
+	s.Equal(map[string]float64{"1000": 2, "1001": 1, "other": 2}, uids)
+	gids := map[string]float64{}
+	for _, m := range filterMetrics(metrics, "sftp_group_objects_available") {
+		gids[labels(m)["gid"]] = m.GetGauge().GetValue()
+	}
+	s.Equal(map[string]float64{"100": 3, "101": 2}, gids)
+	violations := map[string]float64{}
+	for _, m := range filterMetrics(metrics, "sftp_objects_policy_violations") {
+		violations[labels(m)["rule"]] = m.GetGauge().GetValue()
+	}
+	s.Equal(map[string]float64{"mode": 2, "uid": 3}, violations)
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldOnlyListChangedDirectoriesWhenIncremental() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPIncremental, true)
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/path0/a.txt", []byte("a"), 0644)
+	_ = afero.WriteFile(memFs, "/path0/dir1/b.txt", []byte("b"), 0644)
+	_ = afero.WriteFile(memFs, "/path0/dir2/c.txt", []byte("c"), 0644)
+	_ = memFs.Mkdir("/path0/dir3", 0755)
+	modTime := time.Now().Add(-time.Hour)
+	for _, dir := range []string{"/path0", "/path0/dir1", "/path0/dir2", "/path0/dir3"} {
+		_ = memFs.Chtimes(dir, modTime, modTime)
+	}
+	listingFs := listingKrFs{memKrFs: memKrFs{memFs: memFs}, mu: &sync.Mutex{}, dirs: new([]string)}
+	s.sftpClient.EXPECT().Connect().Return(nil).Times(3)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, listingFs)
+	}).AnyTimes()
+	s.sftpClient.EXPECT().Close().Times(3)
+
+	gauge := func(metrics []prometheus.Metric, name string) float64 {
+		filtered := filterMetrics(metrics, name)
+		s.Len(filtered, 1)
+		return filtered[0].GetGauge().GetValue()
+	}
+
+	metrics := s.collect()
+	s.Equal([]string{"/path0", "/path0/dir1", "/path0/dir2", "/path0/dir3"}, listingFs.listed())
+	s.Equal(3.0, gauge(metrics, "sftp_objects_available"))
+	s.Equal(0.0, gauge(metrics, "sftp_directory_cache_hit_ratio"))
+
+	metrics = s.collect()
+	s.Empty(listingFs.listed())
+	s.Equal(3.0, gauge(metrics, "sftp_objects_available"))
+	s.Equal(3.0, gauge(metrics, "sftp_objects_total_size_bytes"))
+	s.Equal(3.0, gauge(metrics, "sftp_directories_available"))
+	s.Equal(1.0, gauge(metrics, "sftp_empty_directories"))
+	s.Equal(1.0, gauge(metrics, "sftp_directory_cache_hit_ratio"))
+
+	_ = afero.WriteFile(memFs, "/path0/dir2/d.txt", []byte("d"), 0644)
+	_ = memFs.Chtimes("/path0/dir2", modTime.Add(time.Minute), modTime.Add(time.Minute))
+	metrics = s.collect()
+	s.Equal([]string{"/path0/dir2"}, listingFs.listed())
+	s.Equal(4.0, gauge(metrics, "sftp_objects_available"))
+	s.Equal(0.75, gauge(metrics, "sftp_directory_cache_hit_ratio"))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldCountObjectChanges() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPMaxTrackedObjects, 3)
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/path0/a.txt", []byte("a"), 0644)
+	_ = afero.WriteFile(memFs, "/path0/dir/b.txt", []byte("b"), 0644)
+	s.sftpClient.EXPECT().Connect().Return(nil).Times(5)
+	s.sftpClient.EXPECT().Walk("/path0").DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(5)
+	s.sftpClient.EXPECT().Close().Times(5)
+
+	counters := func(metrics []prometheus.Metric) []float64 {
+		var values []float64
+		for _, name := range []string{"sftp_objects_created_total", "sftp_objects_removed_total",
+			"sftp_objects_modified_total", "sftp_bytes_added_total"} {
+			filtered := filterMetrics(metrics, name)
+			s.Len(filtered, 1)
+			values = append(values, filtered[0].GetCounter().GetValue())
+		}
+		return values
+	}
+
+	s.Equal([]float64{0, 0, 0, 0}, counters(s.collect()))
+
+	_ = afero.WriteFile(memFs, "/path0/c.txt", []byte("ccc"), 0644)
+	_ = afero.WriteFile(memFs, "/path0/a.txt", []byte("aaaa"), 0644)
+	_ = memFs.Remove("/path0/dir/b.txt")
+	s.Equal([]float64{1, 1, 1, 3 + 3}, counters(s.collect()))
+
+	// changes are not tracked past the maximum number of objects
+	for _, name := range []string{"/path0/d.txt", "/path0/e.txt", "/path0/f.txt"} {
+		_ = afero.WriteFile(memFs, name, []byte("d"), 0644)
+	}
+	s.Equal([]float64{1, 1, 1, 6}, counters(s.collect()))
+
+	_ = memFs.Remove("/path0/d.txt")
+	_ = memFs.Remove("/path0/e.txt")
+	s.Equal([]float64{1, 1, 1, 6}, counters(s.collect()))
+
+	_ = memFs.Remove("/path0/f.txt")
+	s.Equal([]float64{1, 2, 1, 6}, counters(s.collect()))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotCountObjectsInProgressAsAvailable() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPMaxTrackedObjects, 10)
+	viper.Set(viperkeys.SFTPStableAfter, 200*time.Millisecond)
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/path0/a.txt", []byte("a"), 0644)
+	_ = memFs.Chtimes("/path0/a.txt", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+	_ = afero.WriteFile(memFs, "/path0/b.txt", []byte("bb"), 0644)
+	s.sftpClient.EXPECT().Connect().Return(nil).Times(7)
+	s.sftpClient.EXPECT().Walk("/path0").DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(7)
+	s.sftpClient.EXPECT().Close().Times(7)
+
+	gauges := func(metrics []prometheus.Metric) []float64 {
+		var values []float64
+		for _, name := range []string{"sftp_objects_available", "sftp_objects_total_size_bytes", "sftp_objects_in_progress"} {
+			for _, metric := range filterMetrics(metrics, name) {
+				values = append(values, metric.GetGauge().GetValue())
+			}
+		}
+		return values
+	}
+
+	// objects in progress are only known once the path was walked twice
+	s.Equal([]float64{2, 3}, gauges(s.collect()))
+	// objects are in progress until unchanged for the stable period since
+	// their modification time or since they were seen changing
+	s.Equal([]float64{1, 1, 1}, gauges(s.collect()))
+	time.Sleep(300 * time.Millisecond)
+	s.Equal([]float64{2, 3, 0}, gauges(s.collect()))
+
+	_ = afero.WriteFile(memFs, "/path0/a.txt", []byte("aaa"), 0644)
+	s.Equal([]float64{1, 2, 1}, gauges(s.collect()))
+
+	// past the maximum number of tracked objects, then walking again
+	// from a reference
+	for i := range 10 {
+		_ = afero.WriteFile(memFs, fmt.Sprintf("/path0/overflow/%d.txt", i), []byte("o"), 0644)
+	}
+	s.Equal([]float64{12, 15}, gauges(s.collect()))
+	_ = memFs.RemoveAll("/path0/overflow")
+	s.Equal([]float64{2, 5}, gauges(s.collect()))
+	s.Equal([]float64{1, 2, 1}, gauges(s.collect()))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotCountObjectsInProgressAsAvailableInTheirClass() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPMaxTrackedObjects, 10)
+	viper.Set(viperkeys.SFTPStableAfter, time.Hour)
+	viper.Set(viperkeys.SFTPObjectClasses, []any{map[string]any{"name": "invoices", "pattern": "*.xml"}})
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/path0/a.xml", []byte("invoice"), 0644)
+	_ = memFs.Chtimes("/path0/a.xml", time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))
+	_ = afero.WriteFile(memFs, "/path0/b.xml", []byte("xml"), 0644)
+	_ = afero.WriteFile(memFs, "/path0/c.txt", []byte("text"), 0644)
+	_ = memFs.Chtimes("/path0/c.txt", time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))
+	s.sftpClient.EXPECT().Connect().Return(nil).Times(2)
+	s.sftpClient.EXPECT().Walk("/path0").DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(2)
+	s.sftpClient.EXPECT().Close().Times(2)
+
+	s.collect()
+	metrics := s.collect()
+
+	counts := map[string]float64{}
+	for _, m := range filterMetrics(metrics, "sftp_objects_available") {
+		counts[labels(m)["class"]] = m.GetGauge().GetValue()
+	}
+	s.Equal(map[string]float64{"invoices": 1, "unclassified": 1}, counts)
+	sizes := map[string]float64{}
+	for _, m := range filterMetrics(metrics, "sftp_objects_total_size_bytes") {
+		sizes[labels(m)["class"]] = m.GetGauge().GetValue()
+	}
+	s.Equal(map[string]float64{"invoices": 7, "unclassified": 4}, sizes)
+	inProgress := filterMetrics(metrics, "sftp_objects_in_progress")
+	s.Len(inProgress, 1)
+	s.Equal(1.0, inProgress[0].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotCountObjectsInProgressOfTruncatedWalk() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPMaxTrackedObjects, 100)
+	viper.Set(viperkeys.SFTPStableAfter, time.Hour)
+	viper.Set(viperkeys.SFTPMaxEntries, 12)
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0"})
+	memFs := afero.NewMemMapFs()
+	for i := range 10 {
+		_ = afero.WriteFile(memFs, fmt.Sprintf("/path0/%d.txt", i), []byte("a"), 0644)
+	}
+	s.sftpClient.EXPECT().Connect().Return(nil).Times(4)
+	s.sftpClient.EXPECT().Walk("/path0").DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(4)
+	s.sftpClient.EXPECT().Close().Times(4)
+
+	gauges := func(metrics []prometheus.Metric) []float64 {
+		var values []float64
+		for _, name := range []string{"sftp_objects_available", "sftp_objects_in_progress", "sftp_objects_truncated"} {
+			for _, metric := range filterMetrics(metrics, name) {
+				values = append(values, metric.GetGauge().GetValue())
+			}
+		}
+		return values
+	}
+
+	s.Equal([]float64{10, 0}, gauges(s.collect()))
+	s.Equal([]float64{0, 10, 0}, gauges(s.collect()))
+
+	// the objects in progress are unknown when the walk is truncated
+	for i := range 20 {
+		_ = memFs.MkdirAll(fmt.Sprintf("/path0/dir%02d", i), 0755)
+	}
+	s.Equal([]float64{10, 1}, gauges(s.collect()))
+
+	for i := range 20 {
+		_ = memFs.RemoveAll(fmt.Sprintf("/path0/dir%02d", i))
+	}
+	s.Equal([]float64{0, 10, 0}, gauges(s.collect()))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteFileMetrics() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPPaths, nil)
+	viper.Set(viperkeys.SFTPFiles, []any{
+		"/upload/manifest.csv",
+		map[string]any{"path": "/upload/batch.done", "match": "^OK"},
+		map[string]any{"path": "/upload/batch.failed", "match": "^OK"},
+		"/upload/large.csv",
+		"/upload/missing.csv",
+	})
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/upload/manifest.csv", []byte("a,1\nb,2\nc,3"), 0644)
+	_ = afero.WriteFile(memFs, "/upload/batch.done", []byte("OK\n"), 0644)
+	_ = afero.WriteFile(memFs, "/upload/batch.failed", []byte("FAILED\n"), 0644)
+	_ = afero.WriteFile(memFs, "/upload/large.csv", make([]byte, 2048), 0644)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Stat(gomock.Any()).DoAndReturn(memFs.Stat).Times(5)
+	s.sftpClient.EXPECT().Open(gomock.Any()).DoAndReturn(func(name string) (io.ReadCloser, error) {
+		return memFs.Open(name)
+	}).Times(3)
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	fileLines := filterMetrics(metrics, "sftp_file_lines")
+	s.Len(fileLines, 3)
+	s.Equal(map[string]string{"path": "/upload/manifest.csv"}, labels(fileLines[0]))
+	s.Equal(3.0, fileLines[0].GetGauge().GetValue())
+	s.Equal(1.0, fileLines[1].GetGauge().GetValue())
+	s.Equal(1.0, fileLines[2].GetGauge().GetValue())
+
+	fileChecksum := filterMetrics(metrics, "sftp_file_checksum_info")
+	s.Len(fileChecksum, 3)
+	s.Equal(map[string]string{
+		"path":   "/upload/batch.done",
+		"sha256": "a12b7cb43c9d9134b5bb1b35e9096b66775d9e92e7611d1cc92b02edd6782a87",
+	}, labels(fileChecksum[1]))
+
+	fileContentMatch := filterMetrics(metrics, "sftp_file_content_match")
+	s.Len(fileContentMatch, 2)
+	s.Equal(map[string]string{"path": "/upload/batch.done"}, labels(fileContentMatch[0]))
+	s.Equal(1.0, fileContentMatch[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/upload/batch.failed"}, labels(fileContentMatch[1]))
+	s.Equal(0.0, fileContentMatch[1].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldGiveUpOnHangingFileRead() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPPaths, nil)
+	viper.Set(viperkeys.SFTPFiles, []string{"/upload/manifest.csv"})
+	viper.Set(viperkeys.ScrapeTimeout, 50*time.Millisecond)
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/upload/manifest.csv", []byte("a,1\n"), 0644)
+	unblock := make(chan struct{})
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Stat("/upload/manifest.csv").DoAndReturn(memFs.Stat)
+	s.sftpClient.EXPECT().Open("/upload/manifest.csv").Return(blockingReader{unblock: unblock}, nil)
+	s.sftpClient.EXPECT().Close().DoAndReturn(func() error {
+		close(unblock)
+		return nil
+	})
+
+	metrics := s.collect()
+
+	s.Empty(filterMetrics(metrics, "sftp_file_lines"))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldGiveUpOnHangingGlob() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPPaths, []string{"/users/*/inbox"})
+	viper.Set(viperkeys.ScrapeTimeout, 50*time.Millisecond)
+	unblock := make(chan struct{})
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Glob("/users/*/inbox").DoAndReturn(func(string) ([]string, error) {
+		<-unblock
+		return nil, fmt.Errorf("connection lost")
+	})
+	s.sftpClient.EXPECT().Close().DoAndReturn(func() error {
+		close(unblock)
+		return nil
+	})
+
+	metrics := s.collect()
+
+	s.Empty(filterMetrics(metrics, "sftp_path_pattern_info"))
+	s.Empty(filterMetrics(metrics, "sftp_objects_available"))
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldExpandPathPatterns() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPTimezone, "UTC")
+	defer viper.Set(viperkeys.SFTPTimezone, "")
+	viper.Set(viperkeys.SFTPPaths, []string{"/users/*/inbox", `/archive/{{ yesterday.Format "2006/01/02" }}`, "/users/a/inbox"})
+	yesterday := "/archive/" + time.Now().UTC().AddDate(0, 0, -1).Format("2006/01/02")
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/users/a/inbox/a.txt", []byte("a"), 0644)
+	_ = afero.WriteFile(memFs, "/users/b/inbox/b.txt", []byte("bb"), 0644)
+	_ = afero.WriteFile(memFs, yesterday+"/c.txt", []byte("ccc"), 0644)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Glob("/users/*/inbox").Return([]string{"/users/a/inbox", "/users/b/inbox"}, nil)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(3)
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	pathPattern := filterMetrics(metrics, "sftp_path_pattern_info")
+	s.Len(pathPattern, 3)
+	s.Equal(map[string]string{"pattern": "/users/*/inbox", "path": "/users/a/inbox"}, labels(pathPattern[0]))
+	s.Equal(map[string]string{"pattern": "/users/*/inbox", "path": "/users/b/inbox"}, labels(pathPattern[1]))
+	s.Equal(map[string]string{"pattern": `/archive/{{ yesterday.Format "2006/01/02" }}`, "path": yesterday}, labels(pathPattern[2]))
+
+	// the literal path was already collected for the pattern
+	objectSize := filterMetrics(metrics, "sftp_objects_total_size_bytes")
+	s.Len(objectSize, 3)
+	s.Equal(map[string]string{"path": "/users/a/inbox", "class": ""}, labels(objectSize[0]))
+	s.Equal(1.0, objectSize[0].GetGauge().GetValue())
+	s.Equal(2.0, objectSize[1].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": yesterday, "class": ""}, labels(objectSize[2]))
+	s.Equal(3.0, objectSize[2].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldNotExpandLiteralBrackets() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPPaths, []string{`/data/\[archive]`, "/data/[old"})
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "/data/[archive]/a.txt", []byte("a"), 0644)
+	_ = afero.WriteFile(memFs, "/data/[old/b.txt", []byte("bb"), 0644)
+	_ = afero.WriteFile(memFs, "/data/a/c.txt", []byte("ccc"), 0644)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk("/data/[archive]").Return(walkFS("/data/[archive]", memKrFs{memFs: memFs}))
+	s.sftpClient.EXPECT().Walk("/data/[old").Return(walkFS("/data/[old", memKrFs{memFs: memFs}))
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	s.Empty(filterMetrics(metrics, "sftp_path_pattern_info"))
+	objectSize := filterMetrics(metrics, "sftp_objects_total_size_bytes")
+	s.Len(objectSize, 2)
+	s.Equal(map[string]string{"path": "/data/[archive]", "class": ""}, labels(objectSize[0]))
+	s.Equal(1.0, objectSize[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/data/[old", "class": ""}, labels(objectSize[1]))
+	s.Equal(2.0, objectSize[1].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCollectShouldWriteRecentObjectMetrics() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPRecentWindows, []string{"15m", "1h", "24h"})
+	viper.Set(viperkeys.SFTPPaths, []any{"/path0", map[string]any{"path": "/path1", "recent-windows": []any{"2h"}}})
+	memFs := afero.NewMemMapFs()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"/path0/a.txt":     time.Minute,
+		"/path0/dir/b.txt": 30 * time.Minute,
+		"/path0/c.txt":     3 * time.Hour,
+		"/path0/d.txt":     48 * time.Hour,
+		"/path1/e.txt":     time.Hour,
+	} {
+		_ = afero.WriteFile(memFs, name, []byte(name), 0644)
+		_ = memFs.Chtimes(name, now.Add(-age), now.Add(-age))
+	}
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(2)
+	s.sftpClient.EXPECT().Close()
+
+	metrics := s.collect()
+
+	objectsRecent := filterMetrics(metrics, "sftp_objects_recent")
+	s.Len(objectsRecent, 4)
+	s.Equal(map[string]string{"path": "/path0", "window": "15m"}, labels(objectsRecent[0]))
+	s.Equal(1.0, objectsRecent[0].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path0", "window": "1h"}, labels(objectsRecent[1]))
+	s.Equal(2.0, objectsRecent[1].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path0", "window": "24h"}, labels(objectsRecent[2]))
+	s.Equal(3.0, objectsRecent[2].GetGauge().GetValue())
+	s.Equal(map[string]string{"path": "/path1", "window": "2h"}, labels(objectsRecent[3]))
+	s.Equal(1.0, objectsRecent[3].GetGauge().GetValue())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorCloseShouldWaitForCollectionInProgress() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPPaths, []string{"/hung"})
+	memFs := afero.NewMemMapFs()
+	_ = memFs.MkdirAll("/hung", 0755)
+	unblock := make(chan struct{})
+	hungFs := blockingKrFs{memKrFs: memKrFs{memFs: memFs}, dirname: "/hung", unblock: unblock}
+	walking := make(chan struct{})
+	closed := make(chan struct{})
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk("/hung").DoAndReturn(func(root string) *walk.Walker {
+		close(walking)
+		return walkFS(root, hungFs)
+	})
+	s.sftpClient.EXPECT().Close().DoAndReturn(func() error {
+		close(closed)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	collector := s.collector.(*SFTPCollector)
+	s.collector = collector.WithContext(ctx)
+
+	collected := make(chan []prometheus.Metric)
+	go func() { collected <- s.collect() }()
+	<-walking
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer closeCancel()
+	s.ErrorIs(collector.Close(closeCtx), context.DeadlineExceeded)
+
+	cancel()
+	close(unblock)
+	s.NoError(collector.Close(context.Background()))
+	<-closed
+	s.NotEmpty(<-collected)
+
+	// no connection is opened once closed
+	s.Empty(s.collect())
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorStatusShouldReportLastConnection() {
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPPaths, nil)
+	viper.Set(viperkeys.SFTPHost, "sftp.example.com")
+	viper.Set(viperkeys.SFTPPort, 2222)
+	collector := s.collector.(*SFTPCollector)
+	s.Equal(TargetStatus{}, collector.Status())
+
+	s.sftpClient.EXPECT().Connect().Return(fmt.Errorf("connection refused")).Times(2)
+	s.collect()
+	s.collect()
+
+	status := collector.Status()
+	s.Equal("sftp.example.com:2222", status.Target)
+	s.False(status.Up)
+	s.NotNil(status.LastConnection)
+	s.Equal("connection refused", status.LastError)
+	s.Equal(2, status.ConsecutiveFailures)
+	s.Nil(status.LastSuccess)
+
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Close()
+	s.collect()
+
+	status = collector.Status()
+	s.True(status.Up)
+	s.Empty(status.LastError)
+	s.Equal(0, status.ConsecutiveFailures)
+	s.NotNil(status.LastSuccess)
+}
+
+func (s *SFTPCollectorSuite) TestSFTPCollectorStatusShouldReportLastWalkOfPaths() {
+	viper.Set(viperkeys.SFTPPaths, []string{"/path0", "/missing", "/errorpath"})
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	memFs := afero.NewMemMapFs()
+	_ = memFs.MkdirAll("/path0", 0755)
+	_ = memFs.MkdirAll("/errorpath", 0755)
+	s.sftpClient.EXPECT().Connect().Return(nil)
+	s.sftpClient.EXPECT().Walk(gomock.Any()).DoAndReturn(func(root string) *walk.Walker {
+		return walkFS(root, memKrFs{memFs: memFs})
+	}).Times(3)
+	s.sftpClient.EXPECT().Close()
+
+	s.collect()
+
+	paths := s.collector.(*SFTPCollector).Status().Paths
+	s.Len(paths, 3)
+	s.Equal("/path0", paths[0].Path)
+	s.Equal(PathOK, paths[0].Result)
+	s.Empty(paths[0].Error)
+	s.Greater(paths[0].DurationSeconds, 0.0)
+	s.Equal("/missing", paths[1].Path)
+	s.Equal(PathMissing, paths[1].Result)
+	s.Equal("/errorpath", paths[2].Path)
+	s.Equal(PathFailed, paths[2].Result)
+	s.Contains(paths[2].Error, "error reading directory")
 }
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"time"
+)
+
+// Results of the last walk of a path.
+const (
+	PathOK        = "ok"
+	PathTruncated = "truncated"
+	PathMissing   = "missing"
+	PathTimedOut  = "timeout"
+	PathFailed    = "error"
+)
+
+// PathStatus is the outcome of the last walk of a path.
+type PathStatus struct {
+	Path string `json:"path"`
+	// Pattern is the configured path the path was expanded from, if any
+	Pattern         string  `json:"pattern,omitempty"`
+	Result          string  `json:"result"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// TargetStatus is the outcome of the last collections from the SFTP server.
+type TargetStatus struct {
+	Target string `json:"target"`
+	// Up tells if the last connection succeeded
+	Up                  bool       `json:"up"`
+	LastConnection      *time.Time `json:"last_connection,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	// LastSuccess is when the last collection that did not time out ended
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	// Paths holds the outcome of the paths walked by the last collection
+	Paths []PathStatus `json:"paths,omitempty"`
+}
+
+func newPathStatus(config pathConfig, result pathResult) PathStatus {
+	status := PathStatus{
+		Path:            config.Path,
+		Pattern:         config.pattern,
+		Result:          PathOK,
+		DurationSeconds: result.duration.Seconds(),
+	}
+	switch {
+	case result.timedOut:
+		status.Result = PathTimedOut
+	case result.missing:
+		status.Result = PathMissing
+	case result.err != nil:
+		status.Result = PathFailed
+	case result.truncated:
+		status.Result = PathTruncated
+	}
+	if result.err != nil {
+		status.Error = result.err.Error()
+	}
+	return status
+}
+
+// Status returns the outcome of the last collections. It is reset when the
+// target changes.
+func (s *SFTPCollector) Status() TargetStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	return s.status
+}
+
+func (s *SFTPCollector) recordConnection(target string, err error, now time.Time) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	if s.status.Target != target {
+		s.status = TargetStatus{Target: target}
+	}
+	s.status.Up = err == nil
+	s.status.LastConnection = &now
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.ConsecutiveFailures++
+		return
+	}
+	s.status.LastError = ""
+	s.status.ConsecutiveFailures = 0
+}
+
+func (s *SFTPCollector) recordPaths(paths []PathStatus) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.status.Paths = paths
+}
+
+func (s *SFTPCollector) recordSuccess(now time.Time) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.status.LastSuccess = &now
+}
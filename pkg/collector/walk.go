@@ -0,0 +1,585 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/client"
+	"github.com/arunvelsriram/sftp-exporter/pkg/client/walk"
+	"github.com/pkg/sftp"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	objectStats struct {
+		count          int
+		size           int64
+		directoryCount int
+		emptyDirCount  int
+		symlinksBroken int
+		// classes holds the stats of the objects per class, when the path
+		// has classes
+		classes map[string]classStats
+		// uids and gids hold the number of objects per owner, when known
+		uids       map[uint32]int
+		gids       map[uint32]int
+		violations map[string]int
+		// recent holds the modification times of the objects modified
+		// within the largest recent window of the path, as of the walk.
+		// Objects only get older, so the listings cached by incremental walks
+		// do not miss any.
+		recent []time.Time
+		// tracked holds the objects, when the changes of the path are
+		// tracked
+		tracked trackedObjects
+	}
+
+	classStats struct {
+		count int
+		size  int64
+	}
+
+	// pathWalk accumulates the object stats of a configured path, which
+	// may be walked by several workers at once.
+	pathWalk struct {
+		config    pathConfig
+		ctx       context.Context
+		cancel    context.CancelCauseFunc
+		start     sync.Once
+		timer     *time.Timer
+		entries   atomic.Int64
+		truncated atomic.Bool
+		missing   atomic.Bool
+		mu        sync.Mutex
+		pending   int
+		stats     objectStats
+		err       error
+		// followed holds the target directories of the links followed so
+		// far, with their links resolved
+		followed []string
+		// resolve resolves the links of the path once, for realPath to be
+		// compared to the followed directories
+		resolve    sync.Once
+		realPath   string
+		resolveErr error
+		// cache holds the listings of the previous walk, if the path is
+		// walked incrementally
+		cache *dirCache
+		// began and ended are when the first task of the path was picked up
+		// and when the last one was done
+		began time.Time
+		ended time.Time
+	}
+
+	pathResult struct {
+		stats     objectStats
+		err       error
+		timedOut  bool
+		truncated bool
+		missing   bool
+		duration  time.Duration
+	}
+
+	walkTask struct {
+		walk *pathWalk
+		root string
+		// walker walks the root, reusing the listing made by the walker of
+		// the parent task, if any
+		walker *walk.Walker
+		// abandoned is set, guarded by the pool mutex, once the worker stops
+		// waiting for the task after the path timed out.
+		abandoned *bool
+	}
+
+	// walkPool walks the paths with a bounded number of workers sharing
+	// the same SFTP client. Subdirectories are handed over to idle workers
+	// so that a single large path does not keep the others waiting.
+	walkPool struct {
+		sftpClient client.SFTPClient
+		tasks      chan walkTask
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+	}
+)
+
+func (o *objectStats) merge(other objectStats) {
+	o.count += other.count
+	o.size += other.size
+	o.directoryCount += other.directoryCount
+	o.emptyDirCount += other.emptyDirCount
+	o.symlinksBroken += other.symlinksBroken
+	for name, class := range other.classes {
+		o.addClass(name, class)
+	}
+	o.uids = mergeCounts(o.uids, other.uids)
+	o.gids = mergeCounts(o.gids, other.gids)
+	o.violations = mergeCounts(o.violations, other.violations)
+	o.recent = append(o.recent, other.recent...)
+	o.tracked.merge(other.tracked)
+}
+
+func mergeCounts[K comparable](counts, other map[K]int) map[K]int {
+	for key, n := range other {
+		counts = addCount(counts, key, n)
+	}
+	return counts
+}
+
+func addCount[K comparable](counts map[K]int, key K, n int) map[K]int {
+	if counts == nil {
+		counts = make(map[K]int)
+	}
+	counts[key] += n
+	return counts
+}
+
+// addObject counts an object of the path, along with its class, its owner and
+// the policy rules it breaks.
+func (o *objectStats) addObject(config pathConfig, name string, info os.FileInfo) {
+	o.count++
+	o.size += info.Size()
+	if len(config.Classes) > 0 {
+		o.addClass(classify(config.Classes, name), classStats{count: 1, size: info.Size()})
+	}
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		o.uids = addCount(o.uids, stat.UID, 1)
+		o.gids = addCount(o.gids, stat.GID, 1)
+	}
+	if config.Policy != nil {
+		for _, rule := range config.Policy.violations(info) {
+			o.violations = addCount(o.violations, rule, 1)
+		}
+	}
+	if len(config.RecentWindows) > 0 && time.Since(info.ModTime()) < slices.Max(config.RecentWindows) {
+		o.recent = append(o.recent, info.ModTime())
+	}
+	if config.MaxTrackedObjects > 0 {
+		o.tracked.add(name, trackedObject{size: info.Size(), modTime: info.ModTime()}, config.MaxTrackedObjects)
+	}
+}
+
+// recentCount returns the number of objects modified within the window before
+// now.
+func (o *objectStats) recentCount(now time.Time, window time.Duration) int {
+	var count int
+	for _, modTime := range o.recent {
+		if now.Sub(modTime) < window {
+			count++
+		}
+	}
+	return count
+}
+
+func (o *objectStats) addClass(name string, stats classStats) {
+	if o.classes == nil {
+		o.classes = make(map[string]classStats)
+	}
+	class := o.classes[name]
+	class.count += stats.count
+	class.size += stats.size
+	o.classes[name] = class
+}
+
+func newPathWalk(ctx context.Context, config pathConfig) *pathWalk {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &pathWalk{config: config, ctx: ctx, cancel: cancel}
+}
+
+// begin starts the path timeout once the first task of the path is picked up
+// by a worker, so that the time spent waiting for a worker is not counted.
+func (p *pathWalk) begin() {
+	p.start.Do(func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.began = time.Now()
+		if p.config.Timeout > 0 {
+			p.timer = time.AfterFunc(p.config.Timeout, func() {
+				p.cancel(context.DeadlineExceeded)
+			})
+		}
+	})
+}
+
+func (p *pathWalk) add() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending++
+}
+
+func (p *pathWalk) done(stats objectStats, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	p.stats.merge(stats)
+	p.pending--
+	if p.pending > 0 {
+		return
+	}
+	p.ended = time.Now()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
+
+// visit counts an entry of the path. It returns false once the entries exceed
+// the limit of the path, after which the walk is truncated.
+func (p *pathWalk) visit() bool {
+	return p.visitN(1)
+}
+
+func (p *pathWalk) visitN(entries int) bool {
+	n := p.entries.Add(int64(entries))
+	if p.config.MaxEntries > 0 && n > int64(p.config.MaxEntries) {
+		p.truncated.Store(true)
+		return false
+	}
+	return true
+}
+
+// follow claims the target directory of a link for the walk. Both the target
+// and the path must have their links resolved, as the same directory has many
+// names otherwise. It returns false when the target overlaps the path or a
+// target followed before, as walking it would count the same objects twice or
+// never end.
+func (p *pathWalk) follow(realPath, target string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, dir := range append([]string{realPath}, p.followed...) {
+		if overlaps(dir, target) {
+			return false
+		}
+	}
+	p.followed = append(p.followed, target)
+	return true
+}
+
+// overlaps tells if one of the directories contains the other.
+func overlaps(a, b string) bool {
+	a, b = path.Clean(a), path.Clean(b)
+	return a == b || isWithin(a, b) || isWithin(b, a)
+}
+
+func isWithin(name, dir string) bool {
+	return strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// result returns the stats of the path and tells if the walk ran out of time.
+// A path that could not be walked before the scrape timed out is reported as
+// timed out as well.
+func (p *pathWalk) result() pathResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := pathResult{stats: p.stats, err: p.err, truncated: p.truncated.Load(), missing: p.missing.Load()}
+	if !p.began.IsZero() {
+		ended := p.ended
+		if p.pending > 0 {
+			ended = time.Now()
+		}
+		result.duration = ended.Sub(p.began)
+	}
+	if p.pending > 0 {
+		result.err = context.Cause(p.ctx)
+		result.timedOut = true
+		return result
+	}
+	result.timedOut = errors.Is(p.err, context.DeadlineExceeded) || errors.Is(p.err, context.Canceled)
+	return result
+}
+
+// walkPaths walks the paths until all of them are done or ctx expires.
+// Paths having a cache are walked incrementally.
+func walkPaths(ctx context.Context, sftpClient client.SFTPClient, configs []pathConfig, caches map[string]*dirCache,
+	concurrency int) []*pathWalk {
+	pool := &walkPool{sftpClient: sftpClient, tasks: make(chan walkTask)}
+	for range max(concurrency, 1) {
+		go pool.work()
+	}
+
+	walks := make([]*pathWalk, len(configs))
+	for i, config := range configs {
+		walks[i] = newPathWalk(ctx, config)
+		walks[i].cache = caches[config.Path]
+	}
+	for _, walk := range walks {
+		walk.add()
+		pool.wg.Add(1)
+		select {
+		case pool.tasks <- walkTask{walk: walk, root: walk.config.Path, abandoned: new(bool)}:
+		case <-ctx.Done():
+			pool.wg.Done()
+		}
+	}
+	pool.wg.Wait()
+	close(pool.tasks)
+
+	if err := ctx.Err(); err != nil {
+		log.WithField("stage", "collecting object metrics").WithError(err).Warn("scrape timed out before walking all the paths")
+	}
+	for _, walk := range walks {
+		walk.cancel(context.Canceled)
+	}
+	return walks
+}
+
+func (p *walkPool) work() {
+	for task := range p.tasks {
+		task.walk.begin()
+		p.run(task)
+		p.wg.Done()
+	}
+}
+
+// run walks the task until the path times out, after which the walk, if still
+// in progress, no longer hands directories over to the other workers.
+func (p *walkPool) run(task walkTask) {
+	stats, err := callWithContext(task.walk.ctx, func() (objectStats, error) {
+		return p.walk(task)
+	})
+	if task.walk.ctx.Err() != nil {
+		p.mu.Lock()
+		*task.abandoned = true
+		p.mu.Unlock()
+	}
+	task.walk.done(stats, err)
+}
+
+// offer hands the directory over to an idle worker, along with the walker of
+// the directory when known. It returns false without blocking when all the
+// workers are busy or the parent task was abandoned.
+func (p *walkPool) offer(parent walkTask, root string, walker *walk.Walker) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if *parent.abandoned {
+		return false
+	}
+
+	p.wg.Add(1)
+	parent.walk.add()
+	select {
+	case p.tasks <- walkTask{walk: parent.walk, root: root, walker: walker, abandoned: new(bool)}:
+		return true
+	default:
+		parent.walk.done(objectStats{}, nil)
+		p.wg.Done()
+		return false
+	}
+}
+
+func (p *walkPool) walk(task walkTask) (objectStats, error) {
+	var stats objectStats
+	ctx := task.walk.ctx
+	fields := log.Fields{"stage": "collecting object metrics", "path": task.walk.config.Path}
+	if ctx.Err() != nil {
+		return stats, context.Cause(ctx)
+	}
+
+	log.WithFields(fields).Debugf("walking %s", task.root)
+	start := time.Now()
+	isPathRoot := task.root == task.walk.config.Path
+	// walkers visit a directory right before its entries, so a directory
+	// is empty when the entry visited after it is not one of its own
+	var emptyDirCandidate string
+	// listings holds the directories listed by the walker, to be cached once
+	// the walk is over
+	listings := make(map[string]*dirListing)
+	walker := task.walker
+	if walker == nil {
+		walker = p.sftpClient.Walk(task.root)
+	}
+	if cache := task.walk.cache; cache != nil {
+		// the cached directories are not listed unless they changed
+		walker.PrefetchIf(func(dir string, info os.FileInfo) bool {
+			return !cache.has(path.Clean(dir), info.ModTime())
+		})
+	}
+	for walker.Step() {
+		if ctx.Err() != nil {
+			log.WithFields(fields).WithError(context.Cause(ctx)).Warnf("walk stopped after %v", time.Since(start))
+			return stats, context.Cause(ctx)
+		}
+		if err := walker.Err(); err != nil {
+			if isPathRoot && walker.Path() == task.root && errors.Is(err, os.ErrNotExist) {
+				task.walk.missing.Store(true)
+			}
+			log.WithFields(fields).WithError(err).Error("failed to walk the path")
+			return stats, err
+		}
+		if emptyDirCandidate != "" {
+			if path.Dir(walker.Path()) != emptyDirCandidate {
+				stats.emptyDirCount++
+			}
+			emptyDirCandidate = ""
+		}
+		if task.walk.truncated.Load() {
+			return stats, nil
+		}
+		// the root of a subdirectory task was already counted by its parent
+		if walker.Path() != task.root || isPathRoot {
+			if !task.walk.visit() {
+				log.WithFields(fields).Debugf("walk stopped after %d entries", task.walk.config.MaxEntries)
+				return stats, nil
+			}
+		}
+
+		listing := listings[path.Dir(walker.Path())]
+		if walker.Path() == task.root {
+			listing = nil
+		}
+		if listing != nil {
+			listing.entries++
+		}
+
+		if walker.Stat().IsDir() {
+			if walker.Path() != task.root {
+				stats.directoryCount++
+				if listing != nil {
+					listing.subdirs = append(listing.subdirs, walker.Path())
+				}
+				// the directory may have been listed ahead, which the
+				// walker taking it over reuses
+				if p.offer(task, walker.Path(), walker.Detach()) {
+					walker.SkipDir()
+					continue
+				}
+			}
+			if cache := task.walk.cache; cache != nil {
+				dir := path.Clean(walker.Path())
+				if cached := cache.lookup(dir, walker.Stat().ModTime()); cached != nil {
+					walker.SkipDir()
+					if !task.walk.visitN(cached.entries) {
+						log.WithFields(fields).Debugf("walk stopped after %d entries", task.walk.config.MaxEntries)
+						return stats, nil
+					}
+					cachedStats, err := p.walkCached(task, dir, cached)
+					stats.merge(cachedStats)
+					if err != nil {
+						return stats, err
+					}
+					continue
+				}
+				listings[dir] = newDirListing(walker.Stat().ModTime())
+			}
+			if walker.Path() != task.root || !isPathRoot {
+				emptyDirCandidate = path.Clean(walker.Path())
+			}
+			continue
+		}
+		if walker.Stat().Mode()&os.ModeSymlink != 0 {
+			// the targets of the links may change without the directory
+			if listing != nil && task.walk.config.Symlinks != SymlinksSkip {
+				listing.cacheable = false
+			}
+			if err := p.symlink(task, walker.Path(), walker.Stat(), &stats); err != nil {
+				return stats, err
+			}
+			continue
+		}
+		stats.addObject(task.walk.config, walker.Path(), walker.Stat())
+		if listing != nil {
+			listing.stats.addObject(task.walk.config, walker.Path(), walker.Stat())
+		}
+	}
+	if emptyDirCandidate != "" {
+		stats.emptyDirCount++
+	}
+	for dir, listing := range listings {
+		task.walk.cache.store(dir, listing)
+	}
+	return stats, nil
+}
+
+// walkCached counts the objects of the directory from its cached listing, then
+// walks its subdirectories.
+func (p *walkPool) walkCached(task walkTask, dir string, listing *dirListing) (objectStats, error) {
+	var stats objectStats
+	stats.merge(listing.stats)
+	stats.directoryCount += len(listing.subdirs)
+	if listing.entries == 0 && dir != path.Clean(task.walk.config.Path) {
+		stats.emptyDirCount++
+	}
+	for _, subdir := range listing.subdirs {
+		if p.offer(task, subdir, nil) {
+			continue
+		}
+		subdirStats, err := p.walk(walkTask{walk: task.walk, root: subdir, abandoned: task.abandoned})
+		stats.merge(subdirStats)
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// realPath returns the path of the walk with its links resolved, resolving it
+// once for all the links followed.
+func (p *walkPool) realPath(walk *pathWalk) (string, error) {
+	walk.resolve.Do(func() {
+		walk.realPath, walk.resolveErr = p.sftpClient.RealPath(walk.config.Path)
+	})
+	return walk.realPath, walk.resolveErr
+}
+
+// symlink counts the link according to the symlinks policy of the path. Links
+// whose target cannot be found are counted as broken unless they are skipped.
+func (p *walkPool) symlink(task walkTask, name string, info os.FileInfo, stats *objectStats) error {
+	policy := task.walk.config.Symlinks
+	if policy == SymlinksSkip {
+		return nil
+	}
+	fields := log.Fields{"stage": "collecting object metrics", "path": task.walk.config.Path}
+
+	target, err := p.sftpClient.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		log.WithFields(fields).Debugf("broken symlink: %s", name)
+		stats.symlinksBroken++
+	} else if err != nil {
+		log.WithFields(fields).WithError(err).Warnf("unable to resolve symlink %s", name)
+	}
+	if policy == SymlinksCount {
+		stats.addObject(task.walk.config, name, info)
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+
+	if !target.IsDir() {
+		stats.addObject(task.walk.config, name, target)
+		return nil
+	}
+	// the server resolves relative targets, .. and the links of the parents
+	dir, err := p.sftpClient.RealPath(name)
+	if err != nil {
+		log.WithFields(fields).WithError(err).Warnf("unable to resolve symlink %s", name)
+		return nil
+	}
+	realPath, err := p.realPath(task.walk)
+	if err != nil {
+		log.WithFields(fields).WithError(err).Warnf("unable to resolve path, not following symlink %s", name)
+		return nil
+	}
+	if !task.walk.follow(realPath, dir) {
+		log.WithFields(fields).Debugf("not following symlink %s, %s overlaps the walked directories", name, dir)
+		return nil
+	}
+
+	// the root of a task was already counted by its parent
+	if name != task.root {
+		stats.directoryCount++
+	}
+	if p.offer(task, dir, nil) {
+		return nil
+	}
+	targetStats, err := p.walk(walkTask{walk: task.walk, root: dir, abandoned: task.abandoned})
+	stats.merge(targetStats)
+	return err
+}
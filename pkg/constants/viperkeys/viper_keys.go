@@ -1,17 +1,63 @@
 package viperkeys
 
 const (
-	ConfigFile        = "config-file"
-	BindAddress       = "bind-address"
-	Port              = "port"
-	LogLevel          = "log-level"
-	SFTPHost          = "sftp-host"
-	SFTPPort          = "sftp-port"
-	SFTPUser          = "sftp-user"
-	SFTPPassword      = "sftp-password"
-	SFTPKey           = "sftp-key"
-	SFTPKeyPassphrase = "sftp-key-passphrase"
-	SFTPStatVfs       = "sftp-statvfs"
-	SFTPPaths         = "sftp-paths"
-	SFTPTimeout       = "sftp-timeout"
+	ConfigFile               = "config-file"
+	BindAddress              = "bind-address"
+	Port                     = "port"
+	LogLevel                 = "log-level"
+	LogFormat                = "log-format"
+	LogRequestsSample        = "log-requests-sample"
+	WebConfigFile            = "web.config.file"
+	WebEnableLifecycle       = "web.enable-lifecycle"
+	ShutdownGracePeriod      = "shutdown-grace-period"
+	ReadyMaxFailures         = "ready-max-failures"
+	ReadyMaxAge              = "ready-max-age"
+	OTLPEndpoint             = "otlp.endpoint"
+	OTLPProtocol             = "otlp.protocol"
+	OTLPInterval             = "otlp.interval"
+	OTLPInsecure             = "otlp.insecure"
+	PushURL                  = "push.url"
+	PushJob                  = "push.job"
+	PushGrouping             = "push.grouping"
+	PushInterval             = "push.interval"
+	PushUsername             = "push.username"
+	PushPassword             = "push.password"
+	PushTLSCAFile            = "push.tls.ca-file"
+	PushTLSCertFile          = "push.tls.cert-file"
+	PushTLSKeyFile           = "push.tls.key-file"
+	PushTLSSkipVerify        = "push.tls.insecure-skip-verify"
+	RemoteWriteURL           = "remote-write.url"
+	RemoteWriteInterval      = "remote-write.interval"
+	RemoteWriteLabels        = "remote-write.labels"
+	RemoteWriteQueueCapacity = "remote-write.queue-capacity"
+	RemoteWriteMinBackoff    = "remote-write.min-backoff"
+	RemoteWriteMaxBackoff    = "remote-write.max-backoff"
+	RemoteWriteUsername      = "remote-write.username"
+	RemoteWritePassword      = "remote-write.password"
+	ScrapeTimeout            = "scrape-timeout"
+	ScrapeTimeoutOffset      = "scrape-timeout-offset"
+	SFTPHost                 = "sftp-host"
+	SFTPPort                 = "sftp-port"
+	SFTPUser                 = "sftp-user"
+	SFTPPassword             = "sftp-password"
+	SFTPKey                  = "sftp-key"
+	SFTPKeyPassphrase        = "sftp-key-passphrase"
+	SFTPStatVfs              = "sftp-statvfs"
+	SFTPPaths                = "sftp-paths"
+	SFTPTimeout              = "sftp-timeout"
+	SFTPMaxConcurrency       = "sftp-max-concurrency"
+	SFTPWalkPrefetch         = "sftp-walk-prefetch"
+	SFTPPathTimeout          = "sftp-path-timeout"
+	SFTPMaxEntries           = "sftp-max-entries"
+	SFTPSymlinks             = "sftp-symlinks"
+	SFTPObjectClasses        = "sftp-object-classes"
+	SFTPObjectPolicy         = "sftp-object-policy"
+	SFTPMaxOwners            = "sftp-max-owners"
+	SFTPIncremental          = "sftp-incremental"
+	SFTPMaxTrackedObjects    = "sftp-max-tracked-objects"
+	SFTPStableAfter          = "sftp-stable-after"
+	SFTPFiles                = "sftp-files"
+	SFTPMaxFileSize          = "sftp-max-file-size"
+	SFTPTimezone             = "sftp-timezone"
+	SFTPRecentWindows        = "sftp-recent-windows"
 )
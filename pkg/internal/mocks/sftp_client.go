@@ -10,9 +10,11 @@
 package mocks
 
 import (
+	io "io"
+	os "os"
 	reflect "reflect"
 
-	fs "github.com/kr/fs"
+	walk "github.com/arunvelsriram/sftp-exporter/pkg/client/walk"
 	sftp "github.com/pkg/sftp"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -69,6 +71,66 @@ func (mr *MockSFTPClientMockRecorder) Connect() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Connect", reflect.TypeOf((*MockSFTPClient)(nil).Connect))
 }
 
+// Glob mocks base method.
+func (m *MockSFTPClient) Glob(pattern string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Glob", pattern)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Glob indicates an expected call of Glob.
+func (mr *MockSFTPClientMockRecorder) Glob(pattern any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Glob", reflect.TypeOf((*MockSFTPClient)(nil).Glob), pattern)
+}
+
+// Open mocks base method.
+func (m *MockSFTPClient) Open(path string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Open", path)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Open indicates an expected call of Open.
+func (mr *MockSFTPClientMockRecorder) Open(path any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockSFTPClient)(nil).Open), path)
+}
+
+// RealPath mocks base method.
+func (m *MockSFTPClient) RealPath(path string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RealPath", path)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RealPath indicates an expected call of RealPath.
+func (mr *MockSFTPClientMockRecorder) RealPath(path any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RealPath", reflect.TypeOf((*MockSFTPClient)(nil).RealPath), path)
+}
+
+// Stat mocks base method.
+func (m *MockSFTPClient) Stat(path string) (os.FileInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stat", path)
+	ret0, _ := ret[0].(os.FileInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stat indicates an expected call of Stat.
+func (mr *MockSFTPClientMockRecorder) Stat(path any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stat", reflect.TypeOf((*MockSFTPClient)(nil).Stat), path)
+}
+
 // StatVFS mocks base method.
 func (m *MockSFTPClient) StatVFS(path string) (*sftp.StatVFS, error) {
 	m.ctrl.T.Helper()
@@ -85,10 +147,10 @@ func (mr *MockSFTPClientMockRecorder) StatVFS(path any) *gomock.Call {
 }
 
 // Walk mocks base method.
-func (m *MockSFTPClient) Walk(root string) *fs.Walker {
+func (m *MockSFTPClient) Walk(root string) *walk.Walker {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Walk", root)
-	ret0, _ := ret[0].(*fs.Walker)
+	ret0, _ := ret[0].(*walk.Walker)
 	return ret0
 }
 
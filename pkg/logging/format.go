@@ -0,0 +1,30 @@
+// Package logging configures the logs of the exporter.
+package logging
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Formats of the logs.
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// NewFormatter returns the logrus formatter of the log format.
+func NewFormatter(format string) (log.Formatter, error) {
+	switch format {
+	case FormatText:
+		return &log.TextFormatter{FullTimestamp: true}, nil
+	case FormatJSON:
+		return &log.JSONFormatter{}, nil
+	case FormatLogfmt:
+		// without colors, the text formatter writes key=value pairs even on a
+		// terminal
+		return &log.TextFormatter{FullTimestamp: true, DisableColors: true}, nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
@@ -0,0 +1,28 @@
+package logging
+
+import (
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFormatter(t *testing.T) {
+	for _, format := range []string{FormatText, FormatJSON, FormatLogfmt} {
+		formatter, err := NewFormatter(format)
+
+		require.NoError(t, err, format)
+		assert.NotNil(t, formatter, format)
+	}
+
+	formatter, err := NewFormatter(FormatJSON)
+	require.NoError(t, err)
+	out, err := formatter.Format(log.WithField("path", "/upload1").WithError(assert.AnError))
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"path":"/upload1"`)
+	assert.Contains(t, string(out), `"error":"`+assert.AnError.Error()+`"`)
+
+	_, err = NewFormatter("xml")
+	assert.EqualError(t, err, `unknown log format "xml"`)
+}
@@ -0,0 +1,106 @@
+package pushgateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/client"
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// closeTimeout is how long to wait for the SFTP connection to be closed once
+// done pushing.
+const closeTimeout = 5 * time.Second
+
+// Run collects the SFTP metrics and pushes them to the Pushgateway, once or on
+// every push interval until ctx is done. Pushing once fails when the metrics
+// cannot be pushed or SFTP cannot be reached, while failures on an interval
+// are only logged.
+func Run(ctx context.Context) error {
+	return run(ctx, client.NewSFTPClient())
+}
+
+func run(ctx context.Context, sftpClient client.SFTPClient) error {
+	httpClient, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+	url := viper.GetString(viperkeys.PushURL)
+	if url == "" {
+		return fmt.Errorf("%s is required", viperkeys.PushURL)
+	}
+	sftpCollector := collector.NewSFTPCollector(sftpClient)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := sftpCollector.Close(ctx); err != nil {
+			log.WithField("stage", "closing collector").WithError(err).Error("SFTP connection still open")
+		}
+	}()
+
+	interval := viper.GetDuration(viperkeys.PushInterval)
+	if interval <= 0 {
+		return pushOnce(ctx, httpClient, url, sftpCollector)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := pushOnce(ctx, httpClient, url, sftpCollector); err != nil {
+			log.WithFields(log.Fields{"stage": "pushing metrics", "url": url}).WithError(err).Error("push failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pushOnce replaces the metrics of the grouping key with the collected ones,
+// so that the paths no longer collected are removed from the Pushgateway.
+func pushOnce(ctx context.Context, httpClient *http.Client, url string, sftpCollector *collector.SFTPCollector) error {
+	pusher := push.New(url, viper.GetString(viperkeys.PushJob)).
+		Client(httpClient).
+		Collector(sftpCollector.WithContext(ctx))
+	for name, value := range viper.GetStringMapString(viperkeys.PushGrouping) {
+		pusher = pusher.Grouping(name, value)
+	}
+	if err := pusher.PushContext(ctx); err != nil {
+		return err
+	}
+	log.WithField("url", url).Debug("metrics pushed")
+
+	if status := sftpCollector.Status(); !status.Up {
+		return fmt.Errorf("failed to connect to %s: %s", status.Target, status.LastError)
+	}
+	return nil
+}
+
+// newHTTPClient returns the client of the Pushgateway, using basic auth and
+// TLS when they are configured.
+func newHTTPClient() (*http.Client, error) {
+	cfg := config.DefaultHTTPClientConfig
+	if username := viper.GetString(viperkeys.PushUsername); username != "" {
+		cfg.BasicAuth = &config.BasicAuth{
+			Username: username,
+			Password: config.Secret(viper.GetString(viperkeys.PushPassword)),
+		}
+	}
+	cfg.TLSConfig = config.TLSConfig{
+		CAFile:             viper.GetString(viperkeys.PushTLSCAFile),
+		CertFile:           viper.GetString(viperkeys.PushTLSCertFile),
+		KeyFile:            viper.GetString(viperkeys.PushTLSKeyFile),
+		InsecureSkipVerify: viper.GetBool(viperkeys.PushTLSSkipVerify),
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid push config: %w", err)
+	}
+	return config.NewClientFromConfig(cfg, "pushgateway")
+}
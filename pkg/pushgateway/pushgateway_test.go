@@ -0,0 +1,169 @@
+package pushgateway
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/arunvelsriram/sftp-exporter/pkg/internal/mocks"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type pushedRequest struct {
+	method   string
+	path     string
+	username string
+	password string
+	body     string
+}
+
+// pushgateway records the pushes it receives, answering with the status.
+type pushgateway struct {
+	mu       sync.Mutex
+	status   int
+	requests []pushedRequest
+}
+
+func (p *pushgateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	username, password, _ := r.BasicAuth()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, pushedRequest{
+		method:   r.Method,
+		path:     r.URL.Path,
+		username: username,
+		password: password,
+		body:     string(body),
+	})
+	w.WriteHeader(p.status)
+}
+
+func (p *pushgateway) pushed() []pushedRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]pushedRequest(nil), p.requests...)
+}
+
+func setUp(t *testing.T, url string) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set(viperkeys.SFTPHost, "sftp.example.com")
+	viper.Set(viperkeys.SFTPPort, 22)
+	viper.Set(viperkeys.SFTPStatVfs, false)
+	viper.Set(viperkeys.SFTPMaxConcurrency, 1)
+	viper.Set(viperkeys.PushURL, url)
+	viper.Set(viperkeys.PushJob, "sftp_exporter")
+	viper.Set(viperkeys.PushGrouping, map[string]string{"instance": "sftp1"})
+}
+
+func TestRunShouldPushOnce(t *testing.T) {
+	gateway := &pushgateway{status: http.StatusOK}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+	setUp(t, server.URL)
+	viper.Set(viperkeys.PushUsername, "pusher")
+	viper.Set(viperkeys.PushPassword, "secret")
+	ctrl := gomock.NewController(t)
+	sftpClient := mocks.NewMockSFTPClient(ctrl)
+	sftpClient.EXPECT().Connect().Return(nil)
+	sftpClient.EXPECT().Close().Return(nil)
+
+	err := run(context.Background(), sftpClient)
+
+	require.NoError(t, err)
+	requests := gateway.pushed()
+	require.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPut, requests[0].method)
+	assert.Equal(t, "/metrics/job/sftp_exporter/instance/sftp1", requests[0].path)
+	assert.Equal(t, "pusher", requests[0].username)
+	assert.Equal(t, "secret", requests[0].password)
+	assert.Contains(t, requests[0].body, "sftp_up")
+}
+
+func TestRunShouldPushOverTLS(t *testing.T) {
+	gateway := &pushgateway{status: http.StatusOK}
+	server := httptest.NewTLSServer(gateway)
+	defer server.Close()
+	setUp(t, server.URL)
+	viper.Set(viperkeys.PushTLSSkipVerify, true)
+	ctrl := gomock.NewController(t)
+	sftpClient := mocks.NewMockSFTPClient(ctrl)
+	sftpClient.EXPECT().Connect().Return(nil)
+	sftpClient.EXPECT().Close().Return(nil)
+
+	err := run(context.Background(), sftpClient)
+
+	require.NoError(t, err)
+	assert.Len(t, gateway.pushed(), 1)
+}
+
+func TestRunShouldFailWhenPushIsRejected(t *testing.T) {
+	gateway := &pushgateway{status: http.StatusInternalServerError}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+	setUp(t, server.URL)
+	ctrl := gomock.NewController(t)
+	sftpClient := mocks.NewMockSFTPClient(ctrl)
+	sftpClient.EXPECT().Connect().Return(nil)
+	sftpClient.EXPECT().Close().Return(nil)
+
+	err := run(context.Background(), sftpClient)
+
+	assert.ErrorContains(t, err, "unexpected status code 500")
+}
+
+func TestRunShouldFailWhenSFTPIsDown(t *testing.T) {
+	gateway := &pushgateway{status: http.StatusOK}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+	setUp(t, server.URL)
+	ctrl := gomock.NewController(t)
+	sftpClient := mocks.NewMockSFTPClient(ctrl)
+	sftpClient.EXPECT().Connect().Return(fmt.Errorf("connection refused"))
+
+	err := run(context.Background(), sftpClient)
+
+	assert.EqualError(t, err, "failed to connect to sftp.example.com:22: connection refused")
+	requests := gateway.pushed()
+	require.Len(t, requests, 1)
+	assert.Contains(t, requests[0].body, "sftp_up")
+}
+
+func TestRunShouldFailWithoutURL(t *testing.T) {
+	setUp(t, "")
+	ctrl := gomock.NewController(t)
+
+	err := run(context.Background(), mocks.NewMockSFTPClient(ctrl))
+
+	assert.EqualError(t, err, "push.url is required")
+}
+
+func TestRunShouldPushOnIntervalUntilDone(t *testing.T) {
+	gateway := &pushgateway{status: http.StatusInternalServerError}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+	setUp(t, server.URL)
+	viper.Set(viperkeys.PushInterval, 10*time.Millisecond)
+	ctrl := gomock.NewController(t)
+	sftpClient := mocks.NewMockSFTPClient(ctrl)
+	sftpClient.EXPECT().Connect().Return(nil).MinTimes(2)
+	sftpClient.EXPECT().Close().Return(nil).MinTimes(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() { done <- run(ctx, sftpClient) }()
+	require.Eventually(t, func() bool { return len(gateway.pushed()) >= 2 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	assert.NoError(t, <-done)
+}
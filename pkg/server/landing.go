@@ -0,0 +1,108 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	log "github.com/sirupsen/logrus"
+)
+
+type landingPage struct {
+	Version string
+	Ready   bool
+	Reason  string
+	Target  collector.TargetStatus
+	Config  configStatus
+	// Lifecycle tells if the config can be reloaded over HTTP
+	Lifecycle bool
+}
+
+var landingTemplate = template.Must(template.New("landing").Funcs(template.FuncMap{
+	"timestamp": func(t *time.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return t.Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>SFTP Exporter</title>
+  <style>
+    body { font-family: sans-serif; margin: 2em; }
+    table { border-collapse: collapse; margin-bottom: 2em; }
+    th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+    .ok { color: #2a7d2a; }
+    .failed { color: #b22222; }
+  </style>
+</head>
+<body>
+  <h1>SFTP Exporter</h1>
+  <p>Version: {{ .Version }}</p>
+  <ul>
+    <li><a href="metrics">/metrics</a> - metrics</li>
+    <li><a href="healthz">/healthz</a> - liveness</li>
+    <li><a href="ready">/ready</a> - readiness</li>
+    <li><a href="status">/status</a> - status as JSON</li>
+    {{ if .Lifecycle }}<li>POST /-/reload - reload the config file</li>{{ end }}
+  </ul>
+  <p>
+    {{ if .Ready }}<span class="ok">Ready</span>{{ else }}<span class="failed">Not ready: {{ .Reason }}</span>{{ end }}.
+    Config {{ .Config.File }} {{ if .Config.LastReloadSuccessful }}loaded{{ else }}<span class="failed">failed to reload: {{ .Config.LastError }}</span>{{ end }}.
+  </p>
+  <h2>Target</h2>
+  {{ with .Target }}{{ if .Target }}
+  <table>
+    <tr><th>Target</th><th>Connection</th><th>Last connection</th><th>Last success</th><th>Error</th></tr>
+    <tr>
+      <td>{{ .Target }}</td>
+      <td>{{ if .Up }}<span class="ok">up</span>{{ else }}<span class="failed">down ({{ .ConsecutiveFailures }} failures)</span>{{ end }}</td>
+      <td>{{ timestamp .LastConnection }}</td>
+      <td>{{ timestamp .LastSuccess }}</td>
+      <td>{{ .LastError }}</td>
+    </tr>
+  </table>
+  <h2>Paths</h2>
+  <table>
+    <tr><th>Path</th><th>Pattern</th><th>Result</th><th>Duration</th><th>Error</th></tr>
+    {{ range .Paths }}
+    <tr>
+      <td>{{ .Path }}</td>
+      <td>{{ .Pattern }}</td>
+      <td class="{{ if eq .Result "ok" }}ok{{ else }}failed{{ end }}">{{ .Result }}</td>
+      <td>{{ printf "%.3fs" .DurationSeconds }}</td>
+      <td>{{ .Error }}</td>
+    </tr>
+    {{ else }}
+    <tr><td colspan="5">No path walked yet</td></tr>
+    {{ end }}
+  </table>
+  {{ else }}
+  <p>Not scraped yet.</p>
+  {{ end }}{{ end }}
+</body>
+</html>
+`))
+
+// landingHandler serves the landing page at the root, linking the endpoints
+// and showing the outcome of the last collection.
+func landingHandler(sftpCollector *collector.SFTPCollector, reloader *configReloader, version string, started time.Time, lifecycle bool) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		page := landingPage{Version: version, Target: sftpCollector.Status(), Config: reloader.status(), Lifecycle: lifecycle}
+		page.Reason = reloader.readiness().notReadyReason(page.Target, started, time.Now())
+		page.Ready = page.Reason == ""
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := landingTemplate.Execute(w, page); err != nil {
+			log.WithField("stage", "writing landing page").WithError(err).Error("failed to write the landing page")
+		}
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLandingHandler(t *testing.T) {
+	reloader := newConfigReloader("sftp-exporter.yaml")
+	handler := landingHandler(collector.NewSFTPCollector(nil), reloader, "v1.2.3", time.Now(), false)
+
+	t.Run("should list the endpoints and the version", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Version: v1.2.3")
+		assert.Contains(t, rec.Body.String(), `<a href="metrics">/metrics</a>`)
+		assert.Contains(t, rec.Body.String(), "Not scraped yet.")
+		assert.NotContains(t, rec.Body.String(), "/-/reload")
+	})
+
+	t.Run("should not serve other paths", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestLandingTemplate(t *testing.T) {
+	lastConnection := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	page := landingPage{
+		Version: "v1.2.3",
+		Ready:   true,
+		Target: collector.TargetStatus{
+			Target:         "sftp.example.com:22",
+			Up:             true,
+			LastConnection: &lastConnection,
+			Paths: []collector.PathStatus{
+				{Path: "/upload1", Result: collector.PathOK, DurationSeconds: 0.25},
+				{Path: "/users/<b>/inbox", Pattern: "/users/*/inbox", Result: collector.PathFailed, Error: "permission denied"},
+			},
+		},
+	}
+
+	var b strings.Builder
+	require.NoError(t, landingTemplate.Execute(&b, page))
+
+	assert.Contains(t, b.String(), "<td>sftp.example.com:22</td>")
+	assert.Contains(t, b.String(), "<td>2026-10-18T12:00:00Z</td>")
+	assert.Contains(t, b.String(), "<td>never</td>")
+	assert.Contains(t, b.String(), "<td>0.250s</td>")
+	assert.Contains(t, b.String(), "<td>/users/&lt;b&gt;/inbox</td>")
+	assert.Contains(t, b.String(), "<td>permission denied</td>")
+}
@@ -3,6 +3,7 @@ package server
 import (
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,7 +30,15 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode
 }
 
+// WithLogging logs every request served by h.
 func WithLogging(h http.Handler) http.Handler {
+	return WithSampledLogging(h, 1)
+}
+
+// WithSampledLogging logs one in every n successful requests served by h, or
+// none of them when n is 0. Failed requests are always logged.
+func WithSampledLogging(h http.Handler, n int) http.Handler {
+	var served atomic.Uint64
 	loggingFn := func(rw http.ResponseWriter, req *http.Request) {
 		start := time.Now()
 
@@ -41,7 +50,12 @@ func WithLogging(h http.Handler) http.Handler {
 		h.ServeHTTP(&lrw, req)
 
 		duration := time.Since(start)
+		observeRequest(req.Pattern, responseData.status, duration)
 
+		sampled := n > 0 && (served.Add(1)-1)%uint64(n) == 0
+		if !sampled && responseData.status < http.StatusBadRequest {
+			return
+		}
 		log.WithFields(log.Fields{
 			"uri":      req.RequestURI,
 			"method":   req.Method,
@@ -51,4 +65,4 @@ func WithLogging(h http.Handler) http.Handler {
 		}).Info("request completed")
 	}
 	return http.HandlerFunc(loggingFn)
-}
\ No newline at end of file
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSampledLogging(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	failed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	tests := []struct {
+		desc    string
+		handler http.Handler
+		n       int
+		logged  int
+	}{
+		{desc: "should log every request", handler: ok, n: 1, logged: 5},
+		{desc: "should log one in n requests", handler: ok, n: 2, logged: 3},
+		{desc: "should not log requests when n is 0", handler: ok, n: 0, logged: 0},
+		{desc: "should always log failed requests", handler: failed, n: 0, logged: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			hook := test.NewGlobal()
+			defer hook.Reset()
+			handler := WithSampledLogging(tt.handler, tt.n)
+
+			for i := 0; i < 5; i++ {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			}
+
+			assert.Len(t, hook.AllEntries(), tt.logged)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	c "github.com/arunvelsriram/sftp-exporter/pkg/constants"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// registry holds the metrics of the exporter itself, gathered along with the
+// SFTP metrics.
+var registry = prometheus.NewRegistry()
+
+var (
+	scrapesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "scrapes_total",
+		Help:      "Number of collections of the SFTP metrics",
+	})
+
+	scrapeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "scrape_duration_seconds",
+		Help:      "Duration of the collections of the SFTP metrics",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	})
+
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by handler and status code",
+	}, []string{"code", "handler"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the HTTP requests by handler",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	remoteWriteSamplesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "remote_write_samples_sent_total",
+		Help:      "Number of samples sent to the remote-write endpoint",
+	})
+
+	remoteWriteSamplesFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "remote_write_samples_failed_total",
+		Help:      "Number of samples rejected by the remote-write endpoint or dropped from the full queue",
+	})
+
+	remoteWriteRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "remote_write_retries_total",
+		Help:      "Number of write requests retried after a recoverable failure",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		scrapesTotal,
+		scrapeDuration,
+		httpRequestsTotal,
+		httpRequestDuration,
+		remoteWriteSamplesSent,
+		remoteWriteSamplesFailed,
+		remoteWriteRetries,
+	)
+}
+
+// newBuildInfo returns the build info metric of the version, along with the
+// revision and Go version of the binary.
+func newBuildInfo(version string) prometheus.Gauge {
+	revision := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "build_info",
+		Help:      "Build information of the exporter, the value being 1",
+		ConstLabels: prometheus.Labels{
+			"version":   version,
+			"revision":  revision,
+			"goversion": runtime.Version(),
+		},
+	})
+	buildInfo.Set(1)
+	return buildInfo
+}
+
+// observeRequest records a request served by the handler registered with the
+// pattern. Handlers that never call WriteHeader answer with 200.
+func observeRequest(pattern string, status int, duration time.Duration) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	httpRequestsTotal.WithLabelValues(strconv.Itoa(status), pattern).Inc()
+	httpRequestDuration.WithLabelValues(pattern).Observe(duration.Seconds())
+}
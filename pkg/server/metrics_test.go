@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLoggingShouldCountRequestsByHandlerAndCode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", WithLogging(healthzHandler()))
+	mux.Handle("/missing", WithLogging(http.NotFoundHandler()))
+	ok := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("200", "/healthz"))
+	notFound := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("404", "/missing"))
+
+	for _, path := range []string{"/healthz", "/healthz", "/missing"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, ok+2, testutil.ToFloat64(httpRequestsTotal.WithLabelValues("200", "/healthz")))
+	assert.Equal(t, notFound+1, testutil.ToFloat64(httpRequestsTotal.WithLabelValues("404", "/missing")))
+}
+
+func TestNewBuildInfo(t *testing.T) {
+	buildInfo := newBuildInfo("1.2.3")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(buildInfo))
+	metric := &dto.Metric{}
+	require.NoError(t, buildInfo.Write(metric))
+	labels := map[string]string{}
+	for _, label := range metric.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	assert.Equal(t, "1.2.3", labels["version"])
+	assert.Equal(t, runtime.Version(), labels["goversion"])
+	assert.NotEmpty(t, labels["revision"])
+}
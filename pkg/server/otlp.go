@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/client"
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	otelprometheus "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Protocols of the OTLP exporter.
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http"
+)
+
+// lockedGatherer gathers the metrics with the config locked for reading, like
+// the scrapes do.
+type lockedGatherer struct {
+	prometheus.Gatherer
+	reloader *configReloader
+}
+
+func (g lockedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.reloader.mu.RLock()
+	defer g.reloader.mu.RUnlock()
+	return g.Gatherer.Gather()
+}
+
+// startOTLP exports the metrics served on /metrics to the OTLP endpoint on
+// every interval, collecting the SFTP metrics each time. It returns the
+// function flushing and stopping the export, or a no-op one when no endpoint
+// is configured.
+func startOTLP(ctx context.Context, sftpCollector *collector.SFTPCollector, reloader *configReloader, version string) (func(context.Context) error, error) {
+	endpoint := viper.GetString(viperkeys.OTLPEndpoint)
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := newOTLPExporter(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	res, err := newOTLPResource(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.WithFields(log.Fields{"stage": "exporting OTLP metrics", "url": endpoint}).WithError(err).Error("OTLP export failed")
+	}))
+
+	sftpRegistry := prometheus.NewRegistry()
+	sftpRegistry.MustRegister(sftpCollector.WithContext(ctx))
+	gatherer := lockedGatherer{Gatherer: prometheus.Gatherers{registry, timedGatherer{sftpRegistry}}, reloader: reloader}
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(viper.GetDuration(viperkeys.OTLPInterval)),
+		sdkmetric.WithProducer(otelprometheus.NewMetricProducer(otelprometheus.WithGatherer(gatherer))),
+	)
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+	return provider.Shutdown, nil
+}
+
+func newOTLPExporter(ctx context.Context, endpoint string) (sdkmetric.Exporter, error) {
+	insecure := viper.GetBool(viperkeys.OTLPInsecure)
+	switch protocol := viper.GetString(viperkeys.OTLPProtocol); protocol {
+	case OTLPProtocolGRPC:
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint)}
+		if insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	case OTLPProtocolHTTP:
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(endpoint)}
+		if insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q", protocol)
+	}
+}
+
+// newOTLPResource describes the exporter, the SFTP target and the host it runs
+// on. Attributes of OTEL_RESOURCE_ATTRIBUTES take precedence.
+func newOTLPResource(ctx context.Context, version string) (*resource.Resource, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "sftp-exporter"),
+			attribute.String("service.version", version),
+			attribute.String("host.name", hostname),
+			attribute.String("sftp.target", client.Target()),
+		),
+		resource.WithFromEnv(),
+	)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/arunvelsriram/sftp-exporter/pkg/internal/mocks"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStartOTLPShouldExportMetrics(t *testing.T) {
+	var mu sync.Mutex
+	var exported []*colmetricpb.ExportMetricsServiceRequest
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := &colmetricpb.ExportMetricsServiceRequest{}
+		require.NoError(t, proto.Unmarshal(body, req))
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		mu.Lock()
+		exported = append(exported, req)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+	viper.Set(viperkeys.OTLPEndpoint, receiver.Listener.Addr().String())
+	viper.Set(viperkeys.OTLPProtocol, OTLPProtocolHTTP)
+	viper.Set(viperkeys.OTLPInsecure, true)
+	viper.Set(viperkeys.OTLPInterval, time.Hour)
+	viper.Set(viperkeys.SFTPHost, "sftp.example.com")
+	viper.Set(viperkeys.SFTPPort, 22)
+	defer func() {
+		viper.Set(viperkeys.OTLPEndpoint, "")
+		viper.Set(viperkeys.SFTPHost, nil)
+		viper.Set(viperkeys.SFTPPort, nil)
+	}()
+	ctrl := gomock.NewController(t)
+	sftpClient := mocks.NewMockSFTPClient(ctrl)
+	sftpClient.EXPECT().Connect().Return(fmt.Errorf("connection refused"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stop, err := startOTLP(ctx, collector.NewSFTPCollector(sftpClient), newConfigReloader(""), "1.2.3")
+	require.NoError(t, err)
+	// stopping flushes the metrics
+	require.NoError(t, stop(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, exported, 1)
+	resourceMetrics := exported[0].GetResourceMetrics()
+	require.Len(t, resourceMetrics, 1)
+	attributes := map[string]string{}
+	for _, attribute := range resourceMetrics[0].GetResource().GetAttributes() {
+		attributes[attribute.GetKey()] = attribute.GetValue().GetStringValue()
+	}
+	assert.Equal(t, "sftp.example.com:22", attributes["sftp.target"])
+	assert.Equal(t, "1.2.3", attributes["service.version"])
+	assert.NotEmpty(t, attributes["host.name"])
+	var up []float64
+	for _, scopeMetrics := range resourceMetrics[0].GetScopeMetrics() {
+		for _, metric := range scopeMetrics.GetMetrics() {
+			if metric.GetName() == "sftp_up" {
+				for _, point := range metric.GetGauge().GetDataPoints() {
+					up = append(up, point.GetAsDouble())
+				}
+			}
+		}
+	}
+	assert.Equal(t, []float64{0}, up)
+}
+
+func TestStartOTLPShouldBeDisabledWithoutEndpoint(t *testing.T) {
+	viper.Set(viperkeys.OTLPEndpoint, "")
+	ctrl := gomock.NewController(t)
+
+	stop, err := startOTLP(context.Background(), collector.NewSFTPCollector(mocks.NewMockSFTPClient(ctrl)), newConfigReloader(""), "1.2.3")
+
+	require.NoError(t, err)
+	assert.NoError(t, stop(context.Background()))
+}
+
+func TestStartOTLPShouldRejectUnknownProtocol(t *testing.T) {
+	viper.Set(viperkeys.OTLPEndpoint, "localhost:4317")
+	viper.Set(viperkeys.OTLPProtocol, "thrift")
+	defer viper.Set(viperkeys.OTLPEndpoint, "")
+	ctrl := gomock.NewController(t)
+
+	_, err := startOTLP(context.Background(), collector.NewSFTPCollector(mocks.NewMockSFTPClient(ctrl)), newConfigReloader(""), "1.2.3")
+
+	assert.EqualError(t, err, `unknown OTLP protocol "thrift"`)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	c "github.com/arunvelsriram/sftp-exporter/pkg/constants"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	configReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "config_last_reload_successful",
+		Help:      "Tells if the last reload of the config file succeeded",
+	})
+
+	configReloadSuccessTime = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: c.Namespace,
+		Subsystem: "exporter",
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful reload of the config file",
+	})
+)
+
+func init() {
+	registry.MustRegister(configReloadSuccessful, configReloadSuccessTime)
+}
+
+type (
+	// configReloader re-reads the config file, keeping the previous config
+	// when the new one is invalid. Scrapes hold the lock for reading, so that
+	// they see the settings of a single config.
+	configReloader struct {
+		mu     sync.RWMutex
+		path   string
+		loaded []byte
+		// stateMu guards the state and the readiness policy of the config in
+		// use, which the probes read without waiting for a reload
+		stateMu sync.Mutex
+		state   configStatus
+		policy  readinessPolicy
+	}
+
+	configStatus struct {
+		File                 string    `json:"file"`
+		LastReloadSuccessful bool      `json:"last_reload_successful"`
+		LastReloadSuccess    time.Time `json:"last_reload_success"`
+		LastError            string    `json:"last_error,omitempty"`
+	}
+)
+
+func newConfigReloader(path string) *configReloader {
+	loaded, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.WithFields(log.Fields{"stage": "reading config file", "path": path}).WithError(err).Warn("config file cannot be reloaded")
+	}
+	reloader := &configReloader{path: path, loaded: loaded, state: configStatus{File: path}}
+	reloader.setState(nil)
+	return reloader
+}
+
+// status returns the outcome of the last reload, the initial load counting as
+// a successful one.
+func (r *configReloader) status() configStatus {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.state
+}
+
+// readiness returns the readiness policy of the config in use.
+func (r *configReloader) readiness() readinessPolicy {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.policy
+}
+
+func (r *configReloader) setState(err error) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	r.state.LastReloadSuccessful = err == nil
+	if err != nil {
+		configReloadSuccessful.Set(0)
+		r.state.LastError = err.Error()
+		return
+	}
+	now := time.Now()
+	configReloadSuccessful.Set(1)
+	configReloadSuccessTime.Set(float64(now.UnixNano()) / 1e9)
+	r.state.LastReloadSuccess = now
+	r.state.LastError = ""
+	r.policy = loadReadinessPolicy()
+}
+
+// reload applies the config file once the scrapes in progress are done. Flags
+// and environment variables still take precedence over the file.
+func (r *configReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fields := log.Fields{"stage": "reloading config", "path": r.path}
+
+	err := r.apply()
+	r.setState(err)
+	if err != nil {
+		log.WithFields(fields).WithError(err).Error("keeping the previous config")
+		if err := viper.ReadConfig(bytes.NewReader(r.loaded)); err != nil {
+			log.WithFields(fields).WithError(err).Error("failed to restore the previous config")
+		}
+		return err
+	}
+	log.WithFields(fields).Info("config reloaded")
+	return nil
+}
+
+func (r *configReloader) apply() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	if err := viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	level, err := log.ParseLevel(viper.GetString(viperkeys.LogLevel))
+	if err != nil {
+		return err
+	}
+	if err := collector.ValidateConfig(); err != nil {
+		return err
+	}
+	log.SetLevel(level)
+	r.loaded = data
+	return nil
+}
+
+// reloadHandler reloads the config on POST, when the lifecycle endpoints are
+// enabled.
+func reloadHandler(reloader *configReloader, lifecycle bool) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !lifecycle {
+			http.Error(w, fmt.Sprintf("lifecycle API is not enabled, start with --%s", viperkeys.WebEnableLifecycle), http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed, use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reloader.reload(); err != nil {
+			http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "config reloaded")
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+// setUpConfig resets viper to the config file, resetting it again once the
+// test is done.
+func setUpConfig(t *testing.T, path string) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigFile(path)
+	require.NoError(t, viper.ReadInConfig())
+	viper.Set(viperkeys.LogLevel, "info")
+}
+
+func TestConfigReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sftp-exporter.yaml")
+	writeConfig(t, path, "sftp-paths: [/upload1]\n")
+	setUpConfig(t, path)
+	reloader := newConfigReloader(path)
+	handler := reloadHandler(reloader, true)
+
+	t.Run("should apply valid config", func(t *testing.T) {
+		writeConfig(t, path, "sftp-paths: [/upload1, /upload2]\n")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"/upload1", "/upload2"}, viper.GetStringSlice(viperkeys.SFTPPaths))
+		assert.Equal(t, 1.0, testutil.ToFloat64(configReloadSuccessful))
+	})
+
+	t.Run("should keep previous config when new one is invalid", func(t *testing.T) {
+		writeConfig(t, path, "sftp-paths: [/upload3]\nsftp-symlinks: resolve\n")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), `unknown symlinks policy "resolve"`)
+		assert.Equal(t, []string{"/upload1", "/upload2"}, viper.GetStringSlice(viperkeys.SFTPPaths))
+		assert.Equal(t, 0.0, testutil.ToFloat64(configReloadSuccessful))
+	})
+
+	t.Run("should only reload on POST", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("should refuse to reload when lifecycle is not enabled", func(t *testing.T) {
+		writeConfig(t, path, "sftp-paths: [/upload4]\n")
+
+		rec := httptest.NewRecorder()
+		reloadHandler(reloader, false).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, []string{"/upload1", "/upload2"}, viper.GetStringSlice(viperkeys.SFTPPaths))
+	})
+
+	t.Run("should apply readiness policy of reloaded config", func(t *testing.T) {
+		writeConfig(t, path, "sftp-paths: [/upload1]\nready-max-failures: 3\n")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 3, reloader.readiness().maxFailures)
+	})
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteTimeout is how long to wait for the endpoint to accept a write
+// request.
+const remoteWriteTimeout = 30 * time.Second
+
+type (
+	// remoteWriter sends the SFTP metrics to a remote-write endpoint on every
+	// interval. Write requests wait in a bounded in-memory queue while the
+	// endpoint is unavailable, the oldest ones being dropped when it is full.
+	remoteWriter struct {
+		url        string
+		client     *http.Client
+		gatherer   prometheus.Gatherer
+		labels     map[string]string
+		interval   time.Duration
+		minBackoff time.Duration
+		maxBackoff time.Duration
+		userAgent  string
+		queue      chan writeRequest
+	}
+
+	// writeRequest is a snappy compressed WriteRequest protobuf message.
+	writeRequest struct {
+		body    []byte
+		samples int
+	}
+
+	label struct {
+		name  string
+		value string
+	}
+
+	timeSeries struct {
+		labels    []label
+		value     float64
+		timestamp int64
+	}
+
+	// recoverableError is returned when the write request can be retried.
+	recoverableError struct {
+		error
+	}
+)
+
+// startRemoteWrite sends the SFTP metrics to the remote-write endpoint until
+// the returned function is called, or does nothing when no endpoint is
+// configured.
+func startRemoteWrite(sftpCollector *collector.SFTPCollector, reloader *configReloader, version string) (func(context.Context) error, error) {
+	url := viper.GetString(viperkeys.RemoteWriteURL)
+	if url == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	capacity := viper.GetInt(viperkeys.RemoteWriteQueueCapacity)
+	if capacity < 1 {
+		return nil, fmt.Errorf("invalid %s: must be positive", viperkeys.RemoteWriteQueueCapacity)
+	}
+	interval := viper.GetDuration(viperkeys.RemoteWriteInterval)
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid %s: must be positive", viperkeys.RemoteWriteInterval)
+	}
+	minBackoff, maxBackoff := viper.GetDuration(viperkeys.RemoteWriteMinBackoff), viper.GetDuration(viperkeys.RemoteWriteMaxBackoff)
+	if minBackoff <= 0 || maxBackoff < minBackoff {
+		return nil, fmt.Errorf("invalid backoff: %s must be positive and at most %s", viperkeys.RemoteWriteMinBackoff, viperkeys.RemoteWriteMaxBackoff)
+	}
+	cfg := config.DefaultHTTPClientConfig
+	if username := viper.GetString(viperkeys.RemoteWriteUsername); username != "" {
+		cfg.BasicAuth = &config.BasicAuth{
+			Username: username,
+			Password: config.Secret(viper.GetString(viperkeys.RemoteWritePassword)),
+		}
+	}
+	httpClient, err := config.NewClientFromConfig(cfg, "remote_write")
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Timeout = remoteWriteTimeout
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sftpRegistry := prometheus.NewRegistry()
+	sftpRegistry.MustRegister(sftpCollector.WithContext(ctx))
+	w := &remoteWriter{
+		url:        url,
+		client:     httpClient,
+		gatherer:   lockedGatherer{Gatherer: timedGatherer{sftpRegistry}, reloader: reloader},
+		labels:     viper.GetStringMapString(viperkeys.RemoteWriteLabels),
+		interval:   interval,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		userAgent:  "sftp-exporter/" + version,
+		queue:      make(chan writeRequest, capacity),
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		w.collect(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		w.send(ctx)
+	}()
+
+	stop := func(ctx context.Context) error {
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return stop, nil
+}
+
+// collect queues the SFTP metrics on every interval until ctx is done.
+func (w *remoteWriter) collect(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if req, err := w.newWriteRequest(time.Now()); err != nil {
+			log.WithFields(log.Fields{"stage": "collecting remote-write metrics", "url": w.url}).WithError(err).Error("failed to collect the metrics")
+		} else if req.samples > 0 {
+			w.enqueue(req)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enqueue adds the write request to the queue, dropping the oldest request
+// when the queue is full.
+func (w *remoteWriter) enqueue(req writeRequest) {
+	for {
+		select {
+		case w.queue <- req:
+			return
+		default:
+		}
+		select {
+		case dropped := <-w.queue:
+			remoteWriteSamplesFailed.Add(float64(dropped.samples))
+			log.WithFields(log.Fields{"stage": "queueing remote-write metrics", "url": w.url}).
+				Warnf("queue is full, dropping %d samples", dropped.samples)
+		default:
+		}
+	}
+}
+
+// send writes the queued requests to the endpoint until ctx is done.
+func (w *remoteWriter) send(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-w.queue:
+			w.sendWithRetries(ctx, req)
+		}
+	}
+}
+
+// sendWithRetries retries the recoverable failures with an exponential
+// backoff, until the request is sent or ctx is done.
+func (w *remoteWriter) sendWithRetries(ctx context.Context, req writeRequest) {
+	fields := log.Fields{"stage": "sending remote-write metrics", "url": w.url}
+	backoff := w.minBackoff
+	for {
+		err := w.post(ctx, req)
+		if err == nil {
+			remoteWriteSamplesSent.Add(float64(req.samples))
+			return
+		}
+		var recoverable recoverableError
+		if !errors.As(err, &recoverable) || ctx.Err() != nil {
+			remoteWriteSamplesFailed.Add(float64(req.samples))
+			log.WithFields(fields).WithError(err).Errorf("dropping %d samples", req.samples)
+			return
+		}
+		log.WithFields(fields).WithError(err).Warnf("retrying in %s", backoff)
+		remoteWriteRetries.Inc()
+		select {
+		case <-ctx.Done():
+			remoteWriteSamplesFailed.Add(float64(req.samples))
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, w.maxBackoff)
+	}
+}
+
+func (w *remoteWriter) post(ctx context.Context, req writeRequest) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(req.body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", w.userAgent)
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// newWriteRequest gathers the metrics as series sampled at now.
+func (w *remoteWriter) newWriteRequest(now time.Time) (writeRequest, error) {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return writeRequest{}, err
+	}
+	series := toTimeSeries(families, w.labels, now.UnixMilli())
+	return writeRequest{body: snappy.Encode(nil, encodeWriteRequest(series)), samples: len(series)}, nil
+}
+
+// toTimeSeries flattens the metric families into series the way Prometheus
+// scrapes them, with the external labels added and the labels sorted by name.
+func toTimeSeries(families []*dto.MetricFamily, externalLabels map[string]string, timestamp int64) []timeSeries {
+	var series []timeSeries
+	for _, family := range families {
+		name := family.GetName()
+		for _, metric := range family.GetMetric() {
+			add := func(suffix string, value float64, extra ...label) {
+				labels := []label{{name: "__name__", value: name + suffix}}
+				for _, pair := range metric.GetLabel() {
+					labels = append(labels, label{name: pair.GetName(), value: pair.GetValue()})
+				}
+				labels = append(labels, extra...)
+				// labels of the metric take precedence over the external ones
+				for name, value := range externalLabels {
+					if !slices.ContainsFunc(labels, func(l label) bool { return l.name == name }) {
+						labels = append(labels, label{name: name, value: value})
+					}
+				}
+				slices.SortFunc(labels, func(a, b label) int { return strings.Compare(a.name, b.name) })
+				series = append(series, timeSeries{labels: labels, value: value, timestamp: timestamp})
+			}
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				add("", metric.GetGauge().GetValue())
+			case dto.MetricType_COUNTER:
+				add("", metric.GetCounter().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				histogram := metric.GetHistogram()
+				for _, bucket := range histogram.GetBucket() {
+					add("_bucket", float64(bucket.GetCumulativeCount()), label{name: "le", value: formatFloat(bucket.GetUpperBound())})
+				}
+				add("_bucket", float64(histogram.GetSampleCount()), label{name: "le", value: "+Inf"})
+				add("_sum", histogram.GetSampleSum())
+				add("_count", float64(histogram.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				summary := metric.GetSummary()
+				for _, quantile := range summary.GetQuantile() {
+					add("", quantile.GetValue(), label{name: "quantile", value: formatFloat(quantile.GetQuantile())})
+				}
+				add("_sum", summary.GetSampleSum())
+				add("_count", float64(summary.GetSampleCount()))
+			default:
+				add("", metric.GetUntyped().GetValue())
+			}
+		}
+	}
+	return series
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprint(f)
+}
+
+// encodeWriteRequest encodes the series as a prometheus.WriteRequest protobuf
+// message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/arunvelsriram/sftp-exporter/pkg/internal/mocks"
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteReceiver decodes the write requests it receives, answering with
+// the statuses in turn and then with 204.
+type remoteWriteReceiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	headers  []http.Header
+	series   [][]timeSeries
+}
+
+func (r *remoteWriteReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	compressed, err := io.ReadAll(req.Body)
+	require.NoError(r.t, err)
+	body, err := snappy.Decode(nil, compressed)
+	require.NoError(r.t, err)
+	series := decodeWriteRequest(r.t, body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.headers = append(r.headers, req.Header)
+	r.series = append(r.series, series)
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *remoteWriteReceiver) received() [][]timeSeries {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]timeSeries(nil), r.series...)
+}
+
+func consumeMessage(t *testing.T, b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		require.GreaterOrEqual(t, m, 0)
+		fn(num, typ, b[:m])
+		b = b[m:]
+	}
+}
+
+func decodeWriteRequest(t *testing.T, b []byte) []timeSeries {
+	var series []timeSeries
+	consumeMessage(t, b, func(_ protowire.Number, _ protowire.Type, value []byte) {
+		var s timeSeries
+		tsBytes, _ := protowire.ConsumeBytes(value)
+		consumeMessage(t, tsBytes, func(num protowire.Number, _ protowire.Type, value []byte) {
+			field, _ := protowire.ConsumeBytes(value)
+			switch num {
+			case 1:
+				var l label
+				consumeMessage(t, field, func(num protowire.Number, _ protowire.Type, value []byte) {
+					s, _ := protowire.ConsumeString(value)
+					if num == 1 {
+						l.name = s
+					} else {
+						l.value = s
+					}
+				})
+				s.labels = append(s.labels, l)
+			case 2:
+				consumeMessage(t, field, func(num protowire.Number, _ protowire.Type, value []byte) {
+					if num == 1 {
+						bits, _ := protowire.ConsumeFixed64(value)
+						s.value = math.Float64frombits(bits)
+					} else {
+						ts, _ := protowire.ConsumeVarint(value)
+						s.timestamp = int64(ts)
+					}
+				})
+			}
+		})
+		series = append(series, s)
+	})
+	return series
+}
+
+func newTestRemoteWriter(url string, gatherer prometheus.Gatherer) *remoteWriter {
+	return &remoteWriter{
+		url:        url,
+		client:     http.DefaultClient,
+		gatherer:   gatherer,
+		labels:     map[string]string{"site": "edge1", "path": "ignored"},
+		interval:   time.Hour,
+		minBackoff: time.Millisecond,
+		maxBackoff: 4 * time.Millisecond,
+		userAgent:  "sftp-exporter/1.2.3",
+		queue:      make(chan writeRequest, 1),
+	}
+}
+
+func newTestGatherer() prometheus.Gatherer {
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGauge(prometheus.GaugeOpts{Name: "sftp_up", Help: "up"})
+	up.Set(1)
+	objects := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "sftp_objects_available", Help: "objects"}, []string{"path"})
+	objects.WithLabelValues("/upload1").Set(3)
+	registry.MustRegister(up, objects)
+	return registry
+}
+
+func TestRemoteWriterShouldSendSeries(t *testing.T) {
+	receiver := &remoteWriteReceiver{t: t}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	w := newTestRemoteWriter(server.URL, newTestGatherer())
+	sent := testutil.ToFloat64(remoteWriteSamplesSent)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	req, err := w.newWriteRequest(now)
+	require.NoError(t, err)
+	w.sendWithRetries(context.Background(), req)
+
+	received := receiver.received()
+	require.Len(t, received, 1)
+	assert.Equal(t, []timeSeries{
+		{
+			labels:    []label{{"__name__", "sftp_objects_available"}, {"path", "/upload1"}, {"site", "edge1"}},
+			value:     3,
+			timestamp: now.UnixMilli(),
+		},
+		{
+			labels:    []label{{"__name__", "sftp_up"}, {"path", "ignored"}, {"site", "edge1"}},
+			value:     1,
+			timestamp: now.UnixMilli(),
+		},
+	}, received[0])
+	header := receiver.headers[0]
+	assert.Equal(t, "snappy", header.Get("Content-Encoding"))
+	assert.Equal(t, "application/x-protobuf", header.Get("Content-Type"))
+	assert.Equal(t, "0.1.0", header.Get("X-Prometheus-Remote-Write-Version"))
+	assert.Equal(t, sent+2, testutil.ToFloat64(remoteWriteSamplesSent))
+}
+
+func TestRemoteWriterShouldRetryRecoverableFailures(t *testing.T) {
+	receiver := &remoteWriteReceiver{t: t, statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	w := newTestRemoteWriter(server.URL, newTestGatherer())
+	sent := testutil.ToFloat64(remoteWriteSamplesSent)
+	retries := testutil.ToFloat64(remoteWriteRetries)
+
+	req, err := w.newWriteRequest(time.Now())
+	require.NoError(t, err)
+	w.sendWithRetries(context.Background(), req)
+
+	assert.Len(t, receiver.received(), 3)
+	assert.Equal(t, retries+2, testutil.ToFloat64(remoteWriteRetries))
+	assert.Equal(t, sent+2, testutil.ToFloat64(remoteWriteSamplesSent))
+}
+
+func TestRemoteWriterShouldNotRetryRejectedRequests(t *testing.T) {
+	receiver := &remoteWriteReceiver{t: t, statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	w := newTestRemoteWriter(server.URL, newTestGatherer())
+	failed := testutil.ToFloat64(remoteWriteSamplesFailed)
+
+	req, err := w.newWriteRequest(time.Now())
+	require.NoError(t, err)
+	w.sendWithRetries(context.Background(), req)
+
+	assert.Len(t, receiver.received(), 1)
+	assert.Equal(t, failed+2, testutil.ToFloat64(remoteWriteSamplesFailed))
+}
+
+func TestRemoteWriterShouldDropOldestRequestWhenQueueIsFull(t *testing.T) {
+	w := newTestRemoteWriter("", newTestGatherer())
+	failed := testutil.ToFloat64(remoteWriteSamplesFailed)
+
+	w.enqueue(writeRequest{body: []byte("old"), samples: 2})
+	w.enqueue(writeRequest{body: []byte("new"), samples: 3})
+
+	assert.Equal(t, writeRequest{body: []byte("new"), samples: 3}, <-w.queue)
+	assert.Equal(t, failed+2, testutil.ToFloat64(remoteWriteSamplesFailed))
+}
+
+func TestToTimeSeriesShouldFlattenHistograms(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "duration_seconds", Help: "duration", Buckets: []float64{1}})
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+	registry.MustRegister(histogram)
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	series := toTimeSeries(families, nil, 1000)
+
+	assert.Equal(t, []timeSeries{
+		{labels: []label{{"__name__", "duration_seconds_bucket"}, {"le", "1"}}, value: 1, timestamp: 1000},
+		{labels: []label{{"__name__", "duration_seconds_bucket"}, {"le", "+Inf"}}, value: 2, timestamp: 1000},
+		{labels: []label{{"__name__", "duration_seconds_sum"}}, value: 2.5, timestamp: 1000},
+		{labels: []label{{"__name__", "duration_seconds_count"}}, value: 2, timestamp: 1000},
+	}, series)
+}
+
+func TestStartRemoteWriteShouldSendSFTPMetrics(t *testing.T) {
+	receiver := &remoteWriteReceiver{t: t}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	viper.Set(viperkeys.RemoteWriteURL, server.URL)
+	viper.Set(viperkeys.RemoteWriteInterval, time.Hour)
+	viper.Set(viperkeys.RemoteWriteQueueCapacity, 10)
+	viper.Set(viperkeys.RemoteWriteMinBackoff, time.Millisecond)
+	viper.Set(viperkeys.RemoteWriteMaxBackoff, time.Millisecond)
+	defer viper.Set(viperkeys.RemoteWriteURL, "")
+	ctrl := gomock.NewController(t)
+	sftpClient := mocks.NewMockSFTPClient(ctrl)
+	sftpClient.EXPECT().Connect().Return(fmt.Errorf("connection refused"))
+
+	stop, err := startRemoteWrite(collector.NewSFTPCollector(sftpClient), newConfigReloader(""), "1.2.3")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(receiver.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, stop(context.Background()))
+
+	series := receiver.received()[0]
+	require.Len(t, series, 1)
+	assert.Equal(t, []label{{"__name__", "sftp_up"}}, series[0].labels)
+	assert.Equal(t, float64(0), series[0].value)
+}
+
+func TestStartRemoteWriteShouldRejectInvalidConfig(t *testing.T) {
+	viper.Set(viperkeys.RemoteWriteURL, "http://localhost:9090/api/v1/write")
+	viper.Set(viperkeys.RemoteWriteQueueCapacity, 10)
+	viper.Set(viperkeys.RemoteWriteInterval, time.Minute)
+	viper.Set(viperkeys.RemoteWriteMinBackoff, time.Minute)
+	viper.Set(viperkeys.RemoteWriteMaxBackoff, time.Second)
+	defer viper.Set(viperkeys.RemoteWriteURL, "")
+	ctrl := gomock.NewController(t)
+
+	_, err := startRemoteWrite(collector.NewSFTPCollector(mocks.NewMockSFTPClient(ctrl)), newConfigReloader(""), "1.2.3")
+
+	assert.EqualError(t, err, "invalid backoff: remote-write.min-backoff must be positive and at most remote-write.max-backoff")
+}
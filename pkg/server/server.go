@@ -1,8 +1,15 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
 	"github.com/spf13/viper"
@@ -11,21 +18,116 @@ import (
 	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/exporter-toolkit/web"
 	log "github.com/sirupsen/logrus"
 )
 
-func Start() error {
+// closeTimeout is how long to wait for the collection in progress to close its
+// SFTP connection once the requests are cancelled.
+const closeTimeout = 5 * time.Second
+
+// Start serves the metrics until ctx is done, then shuts the server down. The
+// config file is reloaded on SIGHUP.
+func Start(ctx context.Context, version string) error {
 	sftpClient := client.NewSFTPClient()
 	sftpCollector := collector.NewSFTPCollector(sftpClient)
-	prometheus.MustRegister(sftpCollector)
-
-	r := http.NewServeMux()
-	r.Handle("/healthz", WithLogging(healthzHandler()))
-	r.Handle("/metrics", WithLogging(promhttp.Handler()))
+	reloader := newConfigReloader(viper.ConfigFileUsed())
+	started := time.Now()
+	registry.MustRegister(newBuildInfo(version))
+	r := newServeMux(sftpCollector, reloader, version, started)
 
 	addr := fmt.Sprintf("%s:%d", viper.GetString(viperkeys.BindAddress), viper.GetInt(viperkeys.Port))
 	log.Infof("Server will be listening on: %s", addr)
-	return http.ListenAndServe(addr, r)
+	// requests are cancelled once the grace period of the shutdown is over
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	stopOTLP, err := startOTLP(requestsCtx, sftpCollector, reloader, version)
+	if err != nil {
+		return err
+	}
+	stopRemoteWrite, err := startRemoteWrite(sftpCollector, reloader, version)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
+	}
+	systemdSocket := false
+	webConfigFile := viper.GetString(viperkeys.WebConfigFile)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- web.ListenAndServe(server, &web.FlagConfig{
+			WebListenAddresses: &[]string{addr},
+			WebSystemdSocket:   &systemdSocket,
+			WebConfigFile:      &webConfigFile,
+		}, toolkitLogger{})
+	}()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case <-hup:
+			// the reload waits for the scrapes in progress, which must not
+			// hold the shutdown
+			go func() { _ = reloader.reload() }()
+		case <-ctx.Done():
+			stopExports := []func(context.Context) error{stopOTLP, stopRemoteWrite}
+			shutdown(server, sftpCollector, stopExports, cancelRequests, viper.GetDuration(viperkeys.ShutdownGracePeriod))
+			return nil
+		}
+	}
+}
+
+// newServeMux routes the endpoints of the exporter.
+func newServeMux(sftpCollector *collector.SFTPCollector, reloader *configReloader, version string, started time.Time) *http.ServeMux {
+	// the frequent requests of probes and scrapes can be sampled
+	requestsSample := viper.GetInt(viperkeys.LogRequestsSample)
+	lifecycle := viper.GetBool(viperkeys.WebEnableLifecycle)
+
+	r := http.NewServeMux()
+	r.Handle("/", WithLogging(landingHandler(sftpCollector, reloader, version, started, lifecycle)))
+	r.Handle("/healthz", WithSampledLogging(healthzHandler(), requestsSample))
+	r.Handle("/ready", WithLogging(readyHandler(sftpCollector, reloader, started)))
+	r.Handle("/status", WithLogging(statusHandler(sftpCollector, reloader, started)))
+	r.Handle("/metrics", WithSampledLogging(metricsHandler(sftpCollector, reloader), requestsSample))
+	r.Handle("/-/reload", WithLogging(reloadHandler(reloader, lifecycle)))
+	return r
+}
+
+// shutdown drains the requests in progress for up to the grace period before
+// cancelling them, then stops the OTLP and remote-write exports and waits for
+// the SFTP connection to be closed.
+func shutdown(server *http.Server, sftpCollector *collector.SFTPCollector, stopExports []func(context.Context) error, cancelRequests context.CancelFunc, gracePeriod time.Duration) {
+	fields := log.Fields{"stage": "shutting down"}
+	log.WithFields(fields).Infof("shutting down, waiting up to %s for the requests in progress", gracePeriod)
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.WithFields(fields).WithError(err).Warn("cancelling the requests still in progress")
+		cancelRequests()
+		if err := server.Close(); err != nil {
+			log.WithFields(fields).WithError(err).Error("failed to close the server")
+		}
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	for _, stop := range stopExports {
+		if err := stop(ctx); err != nil {
+			log.WithFields(fields).WithError(err).Error("failed to stop exporting the metrics")
+		}
+	}
+	if err := sftpCollector.Close(ctx); err != nil {
+		log.WithFields(fields).WithError(err).Error("SFTP connection still open")
+		return
+	}
+	log.WithFields(fields).Info("server stopped")
 }
 
 func healthzHandler() http.Handler {
@@ -35,3 +137,52 @@ func healthzHandler() http.Handler {
 	}
 	return http.HandlerFunc(fn)
 }
+
+// metricsHandler collects the SFTP metrics within the scrape timeout sent by
+// Prometheus, so that partial results are returned instead of a failed scrape.
+func metricsHandler(sftpCollector *collector.SFTPCollector, reloader *configReloader) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		reloader.mu.RLock()
+		defer reloader.mu.RUnlock()
+		ctx := r.Context()
+		if timeout := scrapeTimeout(r); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		sftpRegistry := prometheus.NewRegistry()
+		sftpRegistry.MustRegister(sftpCollector.WithContext(ctx))
+		gatherers := prometheus.Gatherers{registry, timedGatherer{sftpRegistry}}
+		promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// timedGatherer counts the collections of the SFTP metrics and their duration.
+type timedGatherer struct {
+	prometheus.Gatherer
+}
+
+func (t timedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	start := time.Now()
+	defer func() {
+		scrapesTotal.Inc()
+		scrapeDuration.Observe(time.Since(start).Seconds())
+	}()
+	return t.Gatherer.Gather()
+}
+
+func scrapeTimeout(r *http.Request) time.Duration {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil {
+		log.WithField("stage", "parsing scrape timeout").WithError(err).Warn("ignoring the scrape timeout")
+		return 0
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - viper.GetDuration(viperkeys.ScrapeTimeoutOffset)
+	return max(timeout, time.Millisecond)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	"github.com/arunvelsriram/sftp-exporter/pkg/internal/mocks"
+	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestServerShouldRequireBasicAuthOfWebConfig(t *testing.T) {
+	dir := t.TempDir()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	webConfigFile := filepath.Join(dir, "web-config.yaml")
+	writeConfig(t, webConfigFile, fmt.Sprintf("basic_auth_users:\n  prometheus: %s\n", hash))
+	configFile := filepath.Join(dir, "sftp-exporter.yaml")
+	writeConfig(t, configFile, "sftp-paths: [/upload1]\n")
+	setUpConfig(t, configFile)
+	viper.Set(viperkeys.WebEnableLifecycle, true)
+	ctrl := gomock.NewController(t)
+	sftpClient := mocks.NewMockSFTPClient(ctrl)
+	sftpClient.EXPECT().Connect().Return(fmt.Errorf("connection refused")).AnyTimes()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: newServeMux(collector.NewSFTPCollector(sftpClient), newConfigReloader(configFile), "1.2.3", time.Now())}
+	systemdSocket := false
+	go func() {
+		_ = web.Serve(listener, server, &web.FlagConfig{
+			WebListenAddresses: &[]string{},
+			WebSystemdSocket:   &systemdSocket,
+			WebConfigFile:      &webConfigFile,
+		}, toolkitLogger{})
+	}()
+	defer server.Close()
+	url := "http://" + listener.Addr().String()
+
+	tests := []struct {
+		method   string
+		path     string
+		user     string
+		password string
+		status   int
+	}{
+		{method: http.MethodGet, path: "/metrics", status: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/metrics", user: "prometheus", password: "wrong", status: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/metrics", user: "prometheus", password: "secret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/-/reload", status: http.StatusUnauthorized},
+		{method: http.MethodPost, path: "/-/reload", user: "prometheus", password: "secret", status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %s as %q", test.method, test.path, test.user), func(t *testing.T) {
+			req, err := http.NewRequest(test.method, url+test.path, nil)
+			require.NoError(t, err)
+			if test.user != "" {
+				req.SetBasicAuth(test.user, test.password)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.status, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/arunvelsriram/sftp-exporter/pkg/constants/viperkeys"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type status struct {
+	Ready   bool                     `json:"ready"`
+	Reason  string                   `json:"reason,omitempty"`
+	Targets []collector.TargetStatus `json:"targets"`
+	Config  configStatus             `json:"config"`
+}
+
+// readinessPolicy holds the readiness settings of the config in use.
+type readinessPolicy struct {
+	maxFailures int
+	maxAge      time.Duration
+}
+
+func loadReadinessPolicy() readinessPolicy {
+	return readinessPolicy{
+		maxFailures: viper.GetInt(viperkeys.ReadyMaxFailures),
+		maxAge:      viper.GetDuration(viperkeys.ReadyMaxAge),
+	}
+}
+
+// notReadyReason tells why the exporter is not ready according to the
+// readiness policy, or returns an empty string when it is ready. The exporter
+// is ready until the target is known to be unreachable, so that it gets
+// scraped at all.
+func (p readinessPolicy) notReadyReason(target collector.TargetStatus, started, now time.Time) string {
+	if p.maxFailures > 0 && target.ConsecutiveFailures >= p.maxFailures {
+		return fmt.Sprintf("%d consecutive connection failures to %s: %s", target.ConsecutiveFailures, target.Target, target.LastError)
+	}
+	lastSuccess := started
+	if target.LastSuccess != nil {
+		lastSuccess = *target.LastSuccess
+	}
+	if p.maxAge > 0 && now.Sub(lastSuccess) > p.maxAge {
+		return fmt.Sprintf("no successful collection since %s", lastSuccess.Format(time.RFC3339))
+	}
+	return ""
+}
+
+func readyHandler(sftpCollector *collector.SFTPCollector, reloader *configReloader, started time.Time) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		reason := reloader.readiness().notReadyReason(sftpCollector.Status(), started, time.Now())
+		if reason != "" {
+			http.Error(w, fmt.Sprintf("not ready: %s", reason), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "ready")
+	}
+	return http.HandlerFunc(fn)
+}
+
+func statusHandler(sftpCollector *collector.SFTPCollector, reloader *configReloader, started time.Time) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		target := sftpCollector.Status()
+		reason := reloader.readiness().notReadyReason(target, started, time.Now())
+		s := status{
+			Ready:   reason == "",
+			Reason:  reason,
+			Targets: []collector.TargetStatus{},
+			Config:  reloader.status(),
+		}
+		if target.Target != "" {
+			s.Targets = append(s.Targets, target)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s); err != nil {
+			log.WithField("stage", "writing status").WithError(err).Error("failed to write the status")
+		}
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arunvelsriram/sftp-exporter/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotReadyReason(t *testing.T) {
+	started := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	lastSuccess := started.Add(time.Minute)
+	tests := []struct {
+		desc        string
+		maxFailures int
+		maxAge      time.Duration
+		target      collector.TargetStatus
+		now         time.Time
+		reason      string
+	}{
+		{
+			desc:        "should be ready before the first collection",
+			maxFailures: 1,
+			maxAge:      time.Minute,
+			now:         started,
+		},
+		{
+			desc:        "should not be ready after consecutive connection failures",
+			maxFailures: 2,
+			target:      collector.TargetStatus{Target: "sftp:22", ConsecutiveFailures: 2, LastError: "connection refused"},
+			now:         started,
+			reason:      "2 consecutive connection failures to sftp:22: connection refused",
+		},
+		{
+			desc:   "should ignore connection failures when disabled",
+			target: collector.TargetStatus{Target: "sftp:22", ConsecutiveFailures: 5},
+			now:    started,
+		},
+		{
+			desc:   "should not be ready when last success is too old",
+			maxAge: time.Minute,
+			target: collector.TargetStatus{Target: "sftp:22", LastSuccess: &lastSuccess},
+			now:    lastSuccess.Add(2 * time.Minute),
+			reason: "no successful collection since 2026-10-18T12:01:00Z",
+		},
+		{
+			desc:   "should not be ready when no collection succeeded since start",
+			maxAge: time.Minute,
+			now:    started.Add(2 * time.Minute),
+			reason: "no successful collection since 2026-10-18T12:00:00Z",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			policy := readinessPolicy{maxFailures: test.maxFailures, maxAge: test.maxAge}
+
+			assert.Equal(t, test.reason, policy.notReadyReason(test.target, started, test.now))
+		})
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	reloader := newConfigReloader("sftp-exporter.yaml")
+	reloader.policy = readinessPolicy{maxFailures: 1}
+	handler := statusHandler(collector.NewSFTPCollector(nil), reloader, time.Now())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var s status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+	assert.True(t, s.Ready)
+	assert.Empty(t, s.Targets)
+	assert.Equal(t, "sftp-exporter.yaml", s.Config.File)
+	assert.True(t, s.Config.LastReloadSuccessful)
+}
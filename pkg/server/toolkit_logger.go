@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/go-kit/log/level"
+	log "github.com/sirupsen/logrus"
+)
+
+// toolkitLogger writes the logs of the exporter toolkit, given as key value
+// pairs, with logrus.
+type toolkitLogger struct{}
+
+func (toolkitLogger) Log(keyvals ...any) error {
+	fields := log.Fields{"stage": "serving http"}
+	logLevel := log.InfoLevel
+	var msg string
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		switch value := keyvals[i+1]; {
+		case key == "msg":
+			msg = fmt.Sprint(value)
+		case key == "err":
+			fields[log.ErrorKey] = value
+		case keyvals[i] == level.Key():
+			if parsed, err := log.ParseLevel(fmt.Sprint(value)); err == nil {
+				logLevel = parsed
+			}
+		default:
+			fields[key] = value
+		}
+	}
+	log.WithFields(fields).Log(logLevel, msg)
+	return nil
+}
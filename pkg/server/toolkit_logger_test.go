@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-kit/log/level"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolkitLogger(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	log.SetLevel(log.DebugLevel)
+	tlsErr := errors.New("tls: bad certificate")
+	tests := []struct {
+		desc    string
+		keyvals []any
+		level   log.Level
+		message string
+		fields  log.Fields
+	}{
+		{
+			desc:    "should map the level and the message",
+			keyvals: []any{level.Key(), level.WarnValue(), "msg", "TLS is disabled.", "http2", false},
+			level:   log.WarnLevel,
+			message: "TLS is disabled.",
+			fields:  log.Fields{"stage": "serving http", "http2": false},
+		},
+		{
+			desc:    "should log the error under the error key",
+			keyvals: []any{level.Key(), level.ErrorValue(), "msg", "TLS handshake failed", "err", tlsErr},
+			level:   log.ErrorLevel,
+			message: "TLS handshake failed",
+			fields:  log.Fields{"stage": "serving http", log.ErrorKey: tlsErr},
+		},
+		{
+			desc:    "should default to info without a level",
+			keyvals: []any{"msg", "Listening on", "address", "[::]:8080"},
+			level:   log.InfoLevel,
+			message: "Listening on",
+			fields:  log.Fields{"stage": "serving http", "address": "[::]:8080"},
+		},
+		{
+			desc:    "should ignore a key without value",
+			keyvals: []any{"msg", "Listening on", "dangling"},
+			level:   log.InfoLevel,
+			message: "Listening on",
+			fields:  log.Fields{"stage": "serving http"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			hook.Reset()
+
+			require.NoError(t, toolkitLogger{}.Log(test.keyvals...))
+
+			entry := hook.LastEntry()
+			require.NotNil(t, entry)
+			assert.Equal(t, test.level, entry.Level)
+			assert.Equal(t, test.message, entry.Message)
+			assert.Equal(t, test.fields, entry.Data)
+		})
+	}
+}